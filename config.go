@@ -1,6 +1,11 @@
 package main
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/resource"
+)
 
 type flags struct {
 	Kubeconfig    string
@@ -11,4 +16,39 @@ type flags struct {
 	LogLevel      string
 	LogFormat     string
 	Strict        bool
+	MaxRetries    int
+
+	PropagateLabels      string
+	PropagateAnnotations string
+
+	ReplicateResources resourceConfigs
+
+	LeaderElect              bool
+	LeaderElectNamespace     string
+	LeaderElectLeaseName     string
+	LeaderElectLeaseDuration time.Duration
+	LeaderElectRenewDeadline time.Duration
+	LeaderElectRetryPeriod   time.Duration
+}
+
+// resourceConfigs collects the values of the repeatable -replicate-resource flag
+type resourceConfigs []resource.Config
+
+func (c *resourceConfigs) String() string {
+	values := make([]string, len(*c))
+	for i, config := range *c {
+		values[i] = config.String()
+	}
+
+	return strings.Join(values, " ")
+}
+
+func (c *resourceConfigs) Set(value string) error {
+	config, err := resource.ParseConfig(value)
+	if err != nil {
+		return err
+	}
+
+	*c = append(*c, config)
+	return nil
 }
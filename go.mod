@@ -8,6 +8,7 @@ require (
 	github.com/hashicorp/go-multierror v1.1.0
 	github.com/imdario/mergo v0.3.7 // indirect
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.5.1
 	github.com/sirupsen/logrus v1.6.0
 	github.com/stretchr/testify v1.4.0
 	golang.org/x/sys v0.0.0-20200223170610-d5e6a3e2c0ae // indirect
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// runWithLeaderElection keeps the caches of all replicators warm and only starts replicating once this instance
+// has acquired the leader lease. Losing the lease terminates the process, so that it restarts as a follower.
+func runWithLeaderElection(client kubernetes.Interface, replicators []common.Replicator) {
+	id, err := os.Hostname()
+	if err != nil {
+		log.WithError(err).Fatalf("could not determine leader election identity: %v", err)
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      f.LeaderElectLeaseName,
+			Namespace: f.LeaderElectNamespace,
+		},
+		Client: client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: id,
+		},
+	}
+
+	for _, repl := range replicators {
+		repl.StartInformer()
+	}
+
+	logger := log.WithField("identity", id)
+	logger.Infof("waiting for leader lease %s/%s", f.LeaderElectNamespace, f.LeaderElectLeaseName)
+
+	leaderelection.RunOrDie(context.Background(), newLeaderElectionConfig(lock, replicators, func() {
+		logger.Fatal("lost leader lease, shutting down")
+	}))
+}
+
+// newLeaderElectionConfig builds the leader election for lock. The replicators are started once the lease has been
+// acquired; lostLease is called as soon as it is lost again, or when acquiring it is cancelled.
+func newLeaderElectionConfig(lock resourcelock.Interface, replicators []common.Replicator, lostLease func()) leaderelection.LeaderElectionConfig {
+	id := lock.Identity()
+	logger := log.WithField("identity", id)
+
+	return leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   f.LeaderElectLeaseDuration,
+		RenewDeadline:   f.LeaderElectRenewDeadline,
+		RetryPeriod:     f.LeaderElectRetryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logger.Info("acquired leader lease, starting replication")
+				for _, repl := range replicators {
+					go repl.Run()
+				}
+			},
+			OnStoppedLeading: lostLease,
+			OnNewLeader: func(identity string) {
+				if identity != id {
+					logger.Infof("current leader is %s", identity)
+				}
+			},
+		},
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+type fakeReplicator struct {
+	running chan struct{}
+}
+
+func newFakeReplicator() *fakeReplicator {
+	return &fakeReplicator{running: make(chan struct{})}
+}
+
+func (r *fakeReplicator) Run()                            { close(r.running) }
+func (r *fakeReplicator) StartInformer()                  {}
+func (r *fakeReplicator) Synced() bool                    { return true }
+func (r *fakeReplicator) NamespaceAdded(ns *v1.Namespace) {}
+
+func withLeaderElectionTimings(t *testing.T) {
+	previous := f
+	f.LeaderElectLeaseDuration = time.Second
+	f.LeaderElectRenewDeadline = 500 * time.Millisecond
+	f.LeaderElectRetryPeriod = 100 * time.Millisecond
+	t.Cleanup(func() { f = previous })
+}
+
+func newTestLeaseLock(client kubernetes.Interface, id string) *resourcelock.LeaseLock {
+	return &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      "kubernetes-replicator",
+			Namespace: "kube-system",
+		},
+		Client: client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: id,
+		},
+	}
+}
+
+// runLeaderElection runs the leader election for id in the background and returns a channel that is closed once
+// the lease has been lost.
+func runLeaderElection(ctx context.Context, t *testing.T, client kubernetes.Interface, id string, repl *fakeReplicator) chan struct{} {
+	stopped := make(chan struct{})
+
+	elector, err := leaderelection.NewLeaderElector(newLeaderElectionConfig(newTestLeaseLock(client, id), []common.Replicator{repl}, func() {
+		close(stopped)
+	}))
+	require.NoError(t, err)
+
+	go elector.Run(ctx)
+
+	return stopped
+}
+
+func TestReplicatorsStartOnceTheLeaseIsAcquired(t *testing.T) {
+	withLeaderElectionTimings(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fake.NewSimpleClientset()
+	repl := newFakeReplicator()
+
+	stopped := runLeaderElection(ctx, t, client, "replicator-0", repl)
+
+	select {
+	case <-repl.running:
+	case <-time.After(5 * time.Second):
+		t.Fatal("replicator was not started after acquiring the lease")
+	}
+
+	lease, err := client.CoordinationV1().Leases("kube-system").Get("kubernetes-replicator", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "replicator-0", *lease.Spec.HolderIdentity)
+
+	select {
+	case <-stopped:
+		t.Fatal("lease was lost although it could be renewed")
+	case <-time.After(2 * f.LeaderElectLeaseDuration):
+	}
+}
+
+func TestFollowerTakesOverOnceTheLeaderStops(t *testing.T) {
+	withLeaderElectionTimings(t)
+	leaderCtx, stopLeader := context.WithCancel(context.Background())
+	defer stopLeader()
+	followerCtx, stopFollower := context.WithCancel(context.Background())
+	defer stopFollower()
+
+	client := fake.NewSimpleClientset()
+	leader := newFakeReplicator()
+	follower := newFakeReplicator()
+
+	leaderStopped := runLeaderElection(leaderCtx, t, client, "replicator-0", leader)
+
+	select {
+	case <-leader.running:
+	case <-time.After(5 * time.Second):
+		t.Fatal("leader was not started after acquiring the lease")
+	}
+
+	runLeaderElection(followerCtx, t, client, "replicator-1", follower)
+
+	select {
+	case <-follower.running:
+		t.Fatal("follower was started although the leader keeps renewing the lease")
+	case <-time.After(2 * f.LeaderElectLeaseDuration):
+	}
+
+	stopLeader()
+
+	select {
+	case <-leaderStopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop callback of the leader was not called")
+	}
+
+	select {
+	case <-follower.running:
+	case <-time.After(5 * time.Second):
+		t.Fatal("follower was not started after the leader released the lease")
+	}
+}
+
+func TestLosingTheLeaseStopsReplication(t *testing.T) {
+	withLeaderElectionTimings(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fake.NewSimpleClientset()
+	repl := newFakeReplicator()
+
+	var lost int32
+	client.PrependReactor("update", "leases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if atomic.LoadInt32(&lost) == 1 {
+			return true, nil, errors.New("lease is unavailable")
+		}
+		return false, nil, nil
+	})
+
+	stopped := runLeaderElection(ctx, t, client, "replicator-0", repl)
+
+	select {
+	case <-repl.running:
+	case <-time.After(5 * time.Second):
+		t.Fatal("replicator was not started after acquiring the lease")
+	}
+
+	atomic.StoreInt32(&lost, 1)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop callback was not called after the lease could no longer be renewed")
+	}
+}
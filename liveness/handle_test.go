@@ -17,6 +17,9 @@ type MockReplicator struct {
 func (r *MockReplicator) Run() {
 }
 
+func (r *MockReplicator) StartInformer() {
+}
+
 func (r *MockReplicator) Synced() bool {
 	return r.synced
 }
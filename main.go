@@ -6,15 +6,23 @@ import (
 	"strings"
 	"time"
 
+	"github.com/mittwald/kubernetes-replicator/replicate/clusterrole"
 	"github.com/mittwald/kubernetes-replicator/replicate/common"
 	"github.com/mittwald/kubernetes-replicator/replicate/configmap"
+	"github.com/mittwald/kubernetes-replicator/replicate/limitrange"
+	"github.com/mittwald/kubernetes-replicator/replicate/networkpolicy"
+	"github.com/mittwald/kubernetes-replicator/replicate/resource"
+	"github.com/mittwald/kubernetes-replicator/replicate/resourcequota"
 	"github.com/mittwald/kubernetes-replicator/replicate/role"
 	"github.com/mittwald/kubernetes-replicator/replicate/rolebinding"
 	"github.com/mittwald/kubernetes-replicator/replicate/secret"
+	"github.com/mittwald/kubernetes-replicator/replicate/serviceaccount"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/mittwald/kubernetes-replicator/liveness"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -23,7 +31,6 @@ import (
 var f flags
 
 func init() {
-	var err error
 	flag.StringVar(&f.Kubeconfig, "kubeconfig", "", "path to Kubernetes config file")
 	flag.StringVar(&f.ResyncPeriodS, "resync-period", "30m", "resynchronization period")
 	flag.StringVar(&f.StatusAddr, "status-addr", ":9102", "listen address for status and monitoring server")
@@ -31,6 +38,22 @@ func init() {
 	flag.StringVar(&f.LogFormat, "log-format", "plain", "Log format (plain, json)")
 	flag.BoolVar(&f.AllowAll, "allow-all", false, "allow replication of all secrets (CAUTION: only use when you know what you're doing)")
 	flag.BoolVar(&f.Strict, "strict", false, "actively reset reference secrets if they are altered")
+	flag.BoolVar(&f.LeaderElect, "leader-elect", false, "use leader election so that only one of several replicas replicates at a time")
+	flag.StringVar(&f.LeaderElectNamespace, "leader-elect-namespace", "kube-system", "namespace of the leader election lease")
+	flag.StringVar(&f.LeaderElectLeaseName, "leader-elect-lease-name", "kubernetes-replicator", "name of the leader election lease")
+	flag.DurationVar(&f.LeaderElectLeaseDuration, "leader-elect-lease-duration", 15*time.Second, "duration that followers wait before trying to acquire an unrenewed lease")
+	flag.DurationVar(&f.LeaderElectRenewDeadline, "leader-elect-renew-deadline", 10*time.Second, "duration that the leader retries renewing its lease before giving up")
+	flag.DurationVar(&f.LeaderElectRetryPeriod, "leader-elect-retry-period", 2*time.Second, "duration between two attempts to acquire or renew the lease")
+	flag.IntVar(&f.MaxRetries, "max-retries", 10, "how often a failed replication is retried with exponential backoff before giving up until the next resync (-1 for unlimited)")
+	flag.StringVar(&f.PropagateLabels, "propagate-labels", "", "comma separated list of regular expressions; labels of secrets and config maps matching any of them are copied into their push replicas")
+	flag.StringVar(&f.PropagateAnnotations, "propagate-annotations", "", "comma separated list of regular expressions; annotations of secrets and config maps matching any of them are copied into their push replicas")
+	flag.Var(&f.ReplicateResources, "replicate-resource", "additionally replicate a resource using the dynamic client, as <group>/<version>/<resource>:<path>[,<path>...] (e.g. cert-manager.io/v1alpha2/issuers:spec); can be given multiple times")
+}
+
+// parseFlags parses the command line into f. It is not done in init, so that tests of this package can be run
+// with their own flags.
+func parseFlags() {
+	var err error
 	flag.Parse()
 
 	switch strings.ToUpper(strings.TrimSpace(f.LogLevel)) {
@@ -60,6 +83,7 @@ func init() {
 }
 
 func main() {
+	parseFlags()
 
 	var config *rest.Config
 	var err error
@@ -79,26 +103,56 @@ func main() {
 
 	client = kubernetes.NewForConfigOrDie(config)
 
-	secretRepl := secret.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict)
-	configMapRepl := configmap.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict)
-	roleRepl := role.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict)
-	roleBindingRepl := rolebinding.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict)
-
-	go secretRepl.Run()
+	propagation := common.NewMetadataPropagation(f.PropagateLabels, f.PropagateAnnotations)
+
+	secretRepl := secret.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict, f.MaxRetries, propagation)
+	configMapRepl := configmap.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict, f.MaxRetries, propagation)
+	roleRepl := role.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict, f.MaxRetries)
+	clusterRoleRepl := clusterrole.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict, f.MaxRetries)
+	roleBindingRepl := rolebinding.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict, f.MaxRetries)
+	serviceAccountRepl := serviceaccount.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict, f.MaxRetries)
+	networkPolicyRepl := networkpolicy.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict, f.MaxRetries)
+	limitRangeRepl := limitrange.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict, f.MaxRetries)
+	resourceQuotaRepl := resourcequota.NewReplicator(client, f.ResyncPeriod, f.AllowAll, f.Strict, f.MaxRetries)
+
+	replicators := []common.Replicator{
+		secretRepl,
+		configMapRepl,
+		roleRepl,
+		clusterRoleRepl,
+		roleBindingRepl,
+		serviceAccountRepl,
+		networkPolicyRepl,
+		limitRangeRepl,
+		resourceQuotaRepl,
+	}
 
-	go configMapRepl.Run()
+	if len(f.ReplicateResources) > 0 {
+		dynamicClient := dynamic.NewForConfigOrDie(config)
 
-	go roleRepl.Run()
+		for _, resourceConfig := range f.ReplicateResources {
+			log.Infof("replicating %s", resourceConfig)
+			replicators = append(replicators, resource.NewReplicator(
+				client, dynamicClient, resourceConfig, f.ResyncPeriod, f.AllowAll, f.Strict, f.MaxRetries))
+		}
+	}
 
-	go roleBindingRepl.Run()
+	if f.LeaderElect {
+		go runWithLeaderElection(client, replicators)
+	} else {
+		for _, repl := range replicators {
+			go repl.Run()
+		}
+	}
 
 	h := liveness.Handler{
-		Replicators: []common.Replicator{secretRepl, configMapRepl, roleRepl, roleBindingRepl},
+		Replicators: replicators,
 	}
 
-	log.Infof("starting liveness monitor at %s", f.StatusAddr)
+	log.Infof("starting liveness monitor and metrics endpoint at %s", f.StatusAddr)
 
 	http.Handle("/healthz", &h)
+	http.Handle("/metrics", promhttp.Handler())
 	err = http.ListenAndServe(f.StatusAddr, nil)
 	if err != nil {
 		log.Fatal(err)
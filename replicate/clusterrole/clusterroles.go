@@ -0,0 +1,190 @@
+package clusterrole
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Replicator projects ClusterRoles with ReplicateTo annotation into namespaced Roles of the same name
+type Replicator struct {
+	*common.GenericReplicator
+}
+
+// NewReplicator creates a new cluster role replicator
+func NewReplicator(
+	client kubernetes.Interface,
+	resyncPeriod time.Duration,
+	allowAll bool,
+	strict bool,
+	maxRetries int,
+) common.Replicator {
+	repl := Replicator{
+		GenericReplicator: common.NewGenericReplicator(common.ReplicatorConfig{
+			Kind:         "ClusterRole",
+			ObjType:      &rbacv1.ClusterRole{},
+			AllowAll:     allowAll,
+			Strict:       strict,
+			MaxRetries:   maxRetries,
+			ResyncPeriod: resyncPeriod,
+			Client:       client,
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.RbacV1().ClusterRoles().List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.RbacV1().ClusterRoles().Watch(lo)
+			},
+			PatchFunc: func(namespace string, name string, pt types.PatchType, data []byte) (runtime.Object, error) {
+				return client.RbacV1().ClusterRoles().Patch(name, pt, data)
+			},
+			TargetObjType: &rbacv1.Role{},
+			TargetListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.RbacV1().Roles("").List(lo)
+			},
+			TargetWatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.RbacV1().Roles("").Watch(lo)
+			},
+		}),
+	}
+	repl.UpdateFuncs = common.UpdateFuncs{
+		ReplicateDataFrom:         repl.ReplicateDataFrom,
+		ReplicateObjectTo:         repl.ReplicateObjectTo,
+		PatchDeleteDependent:      repl.PatchDeleteDependent,
+		DeleteReplicatedResource:  repl.DeleteReplicatedResource,
+		ReleaseReplicatedResource: repl.ReleaseReplicatedResource,
+	}
+
+	return &repl
+}
+
+// ReplicateDataFrom is not supported for cluster roles, since cluster roles are only used as source
+func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interface{}) error {
+	return errors.Errorf("cluster role %s cannot be replicated from %s: %s is not supported for cluster roles",
+		common.MustGetKey(targetObj), common.MustGetKey(sourceObj), common.ReplicateFromAnnotation)
+}
+
+// ReplicateObjectTo copies the rules of the cluster role into a role of the same name in target namespace. Rules
+// of aggregated cluster roles are copied as well, since they are part of the cluster role's rules.
+func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
+	source := sourceObj.(*rbacv1.ClusterRole)
+	targetName, err := common.TargetName(source, target.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to namespace %s", common.MustGetKey(source), target.Name)
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
+
+	logger := log.
+		WithField("kind", r.Kind).
+		WithField("source", common.MustGetKey(source)).
+		WithField("target", targetLocation)
+
+	targetResource, exists, err := r.TargetStore.GetByKey(targetLocation)
+	if err != nil {
+		return errors.Wrapf(err, "Could not get %s from cache!", targetLocation)
+	}
+	logger.Infof("Checking if %s exists? %v", targetLocation, exists)
+
+	var targetCopy *rbacv1.Role
+	if exists {
+		targetObject := targetResource.(*rbacv1.Role)
+		targetVersion, ok := targetObject.Annotations[common.ReplicatedFromVersionAnnotation]
+		sourceVersion := common.SourceVersion(source)
+
+		if ok && targetVersion == sourceVersion && r.IsReplicaOf(targetObject, source) {
+			logger.Debugf("Role %s is already up-to-date", common.MustGetKey(targetObject))
+			return nil
+		}
+
+		targetCopy = targetObject.DeepCopy()
+	} else {
+		targetCopy = new(rbacv1.Role)
+	}
+
+	if targetCopy.Annotations == nil {
+		targetCopy.Annotations = make(map[string]string)
+	}
+
+	targetCopy.Name = targetName
+	targetCopy.Rules = make([]rbacv1.PolicyRule, 0, len(source.Rules))
+	for i := range source.Rules {
+		targetCopy.Rules = append(targetCopy.Rules, *source.Rules[i].DeepCopy())
+	}
+	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
+	r.SetSourceReference(source, targetCopy, !exists)
+
+	var obj interface{}
+	if exists {
+		logger.Debugf("Updating existing role %s/%s", target.Name, targetCopy.Name)
+		obj, err = r.Client.RbacV1().Roles(target.Name).Update(targetCopy)
+	} else {
+		logger.Debugf("Creating a new role %s/%s", target.Name, targetCopy.Name)
+		obj, err = r.Client.RbacV1().Roles(target.Name).Create(targetCopy)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Failed to update role %s/%s", target.Name, targetCopy.Name)
+	}
+
+	if err := r.TargetStore.Update(obj); err != nil {
+		return errors.Wrapf(err, "Failed to update cache for %s/%s", target.Name, targetCopy.Name)
+	}
+
+	return nil
+}
+
+// PatchDeleteDependent is not supported for cluster roles, since they cannot have dependents
+func (r *Replicator) PatchDeleteDependent(sourceKey string, target interface{}) (interface{}, error) {
+	return nil, errors.Errorf("cluster role %s cannot depend on %s", common.MustGetKey(target), sourceKey)
+}
+
+// DeleteReplicatedResource deletes a role projected from a cluster role. Roles that were not created by the
+// replicator are left untouched.
+func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"target": targetLocation,
+	})
+
+	object := targetResource.(*rbacv1.Role)
+	if _, ok := object.Annotations[common.ReplicatedFromVersionAnnotation]; !ok {
+		logger.Debugf("Not deleting %s since it has not been replicated", targetLocation)
+		return nil
+	}
+
+	logger.Debugf("Deleting %s", targetLocation)
+	if err := r.Client.RbacV1().Roles(object.Namespace).Delete(object.Name, &metav1.DeleteOptions{}); err != nil {
+		return errors.Wrapf(err, "Failed deleting %s: %v", targetLocation, err)
+	}
+
+	return nil
+}
+
+// ReleaseReplicatedResource removes the projected rules and the source reference from a role that already existed
+// when the cluster role was first projected into it
+func (r *Replicator) ReleaseReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	object := targetResource.(*rbacv1.Role)
+
+	released := object.DeepCopy()
+	released.Rules = nil
+	common.RemoveSourceReference(released)
+
+	log.WithField("kind", r.Kind).WithField("target", targetLocation).Debugf("Releasing %s", targetLocation)
+	s, err := r.Client.RbacV1().Roles(object.Namespace).Update(released)
+	if err != nil {
+		return errors.Wrapf(err, "Failed updating %s: %v", targetLocation, err)
+	}
+
+	return r.TargetStore.Update(s)
+}
@@ -0,0 +1,106 @@
+package clusterrole
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/mittwald/kubernetes-replicator/replicate/role"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestClusterRoleIsProjectedIntoRoles(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-1"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+	)
+
+	repl := NewReplicator(client, time.Minute, false, false, 5)
+	go repl.Run()
+
+	clusterRole := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "tenant-viewer",
+			Annotations: map[string]string{common.ReplicateTo: "tenant-.*"},
+		},
+		Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}},
+	}
+	_, err := client.RbacV1().ClusterRoles().Create(clusterRole)
+	require.NoError(t, err)
+
+	var role *rbacv1.Role
+	require.Eventually(t, func() bool {
+		role, err = client.RbacV1().Roles("tenant-1").Get("tenant-viewer", metav1.GetOptions{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, clusterRole.Rules, role.Rules)
+	require.NotContains(t, role.Annotations, common.ReplicateTo)
+
+	_, err = client.RbacV1().Roles("other").Get("tenant-viewer", metav1.GetOptions{})
+	require.Error(t, err)
+
+	clusterRole.Rules[0].Verbs = append(clusterRole.Rules[0].Verbs, "watch")
+	clusterRole.ResourceVersion = "2"
+	_, err = client.RbacV1().ClusterRoles().Update(clusterRole)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		role, err = client.RbacV1().Roles("tenant-1").Get("tenant-viewer", metav1.GetOptions{})
+		return err == nil && len(role.Rules[0].Verbs) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// Roles and cluster roles are both replicated into roles; the replicators must not collect each other's replicas.
+// The namespace watcher is shared across tests, so this test relies on namespace tenant-1 of the test above.
+func TestRoleAndClusterRoleReplicasAreNotCollectedByEachOther(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-1"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "platform"}},
+		&rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "tenant-viewer",
+				Annotations: map[string]string{common.ReplicateTo: "tenant-1"},
+			},
+		},
+		&rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "tenant-editor",
+				Namespace:   "platform",
+				Annotations: map[string]string{common.ReplicateTo: "tenant-1"},
+			},
+		},
+	)
+
+	clusterRoleRepl := NewReplicator(client, time.Minute, false, false, 5).(*Replicator)
+	roleRepl := role.NewReplicator(client, time.Minute, false, false, 5).(*role.Replicator)
+	go clusterRoleRepl.Run()
+	go roleRepl.Run()
+
+	replicated := func(store cache.Store) bool {
+		for _, key := range []string{"tenant-1/tenant-viewer", "tenant-1/tenant-editor"} {
+			if _, exists, _ := store.GetByKey(key); !exists {
+				return false
+			}
+		}
+		return true
+	}
+	require.Eventually(t, func() bool {
+		return replicated(clusterRoleRepl.TargetStore) && replicated(roleRepl.TargetStore)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	clusterRoleRepl.CollectGarbage()
+	roleRepl.CollectGarbage()
+
+	for _, action := range client.Actions() {
+		require.False(t, action.Matches("delete", "roles"), "unexpected %s", action)
+	}
+	for _, name := range []string{"tenant-viewer", "tenant-editor"} {
+		_, err := client.RbacV1().Roles("tenant-1").Get(name, metav1.GetOptions{})
+		require.NoError(t, err)
+	}
+}
@@ -7,7 +7,11 @@ import (
 )
 
 type Replicator interface {
+	// Run starts the informer and processes replication events. It blocks forever.
 	Run()
+	// StartInformer starts filling the replicator's cache without processing any replication events. Events
+	// received in the meantime are queued and processed once Run is called.
+	StartInformer()
 	Synced() bool
 	NamespaceAdded(ns *v1.Namespace)
 }
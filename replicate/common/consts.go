@@ -2,11 +2,59 @@ package common
 
 // Annotations that are used to control this Controller's behaviour
 const (
-	ReplicateFromAnnotation         = "replicator.v1.mittwald.de/replicate-from"
-	ReplicatedAtAnnotation          = "replicator.v1.mittwald.de/replicated-at"
-	ReplicatedFromVersionAnnotation = "replicator.v1.mittwald.de/replicated-from-version"
-	ReplicatedKeysAnnotation        = "replicator.v1.mittwald.de/replicated-keys"
-	ReplicationAllowed              = "replicator.v1.mittwald.de/replication-allowed"
-	ReplicationAllowedNamespaces    = "replicator.v1.mittwald.de/replication-allowed-namespaces"
-	ReplicateTo                     = "replicator.v1.mittwald.de/replicate-to"
+	ReplicateFromAnnotation              = "replicator.v1.mittwald.de/replicate-from"
+	ReplicatedAtAnnotation               = "replicator.v1.mittwald.de/replicated-at"
+	ReplicatedFromVersionAnnotation      = "replicator.v1.mittwald.de/replicated-from-version"
+	ReplicatedKeysAnnotation             = "replicator.v1.mittwald.de/replicated-keys"
+	ReplicatedKeySourcesAnnotation       = "replicator.v1.mittwald.de/replicated-key-sources"
+	ReplicateKeysAnnotation              = "replicator.v1.mittwald.de/replicate-keys"
+	ExcludeKeysAnnotation                = "replicator.v1.mittwald.de/exclude-keys"
+	ConfigMapAllowedKeysAnnotation       = "replicator.v1.mittwald.de/configmap-allowed-keys"
+	MergeDockerConfigAnnotation          = "replicator.v1.mittwald.de/merge-docker-config"
+	DockerConfigConflictsAnnotation      = "replicator.v1.mittwald.de/docker-config-conflicts"
+	TLSBundleAnnotation                  = "replicator.v1.mittwald.de/tls-bundle"
+	CertificateNotAfterAnnotation        = "replicator.v1.mittwald.de/certificate-not-after"
+	ReplicateKeyMapAnnotation            = "replicator.v1.mittwald.de/replicate-key-map"
+	ReplicateKeyPrefixAnnotation         = "replicator.v1.mittwald.de/replicate-key-prefix"
+	PropagateLabelsAnnotation            = "replicator.v1.mittwald.de/propagate-labels"
+	PropagateAnnotationsAnnotation       = "replicator.v1.mittwald.de/propagate-annotations"
+	ReplicatedLabelsAnnotation           = "replicator.v1.mittwald.de/replicated-labels"
+	ReplicatedAnnotationsAnnotation      = "replicator.v1.mittwald.de/replicated-annotations"
+	ReplicationAllowed                   = "replicator.v1.mittwald.de/replication-allowed"
+	ReplicationAllowedNamespaces         = "replicator.v1.mittwald.de/replication-allowed-namespaces"
+	ReplicationAllowedNamespacesMatching = "replicator.v1.mittwald.de/replication-allowed-namespaces-matching"
+	ReplicateTo                          = "replicator.v1.mittwald.de/replicate-to"
+	ReplicateToMatching                  = "replicator.v1.mittwald.de/replicate-to-matching"
+	ReplicateToNameAnnotation            = "replicator.v1.mittwald.de/replicate-to-name"
+	ReplicationStatusAnnotation          = "replicator.v1.mittwald.de/replication-status"
+	MergeImagePullSecretsAnnotation      = "replicator.v1.mittwald.de/merge-image-pull-secrets"
+	ResourceQuotaOverridesAnnotation     = "replicator.v1.mittwald.de/resource-quota-overrides"
+	AppliedQuotaOverridesAnnotation      = "replicator.v1.mittwald.de/applied-resource-quota-overrides"
+	RecreateOnRoleRefChangeAnnotation    = "replicator.v1.mittwald.de/recreate-on-roleref-change"
+	ReplicateRoleAnnotation              = "replicator.v1.mittwald.de/replicate-role"
+	PushStatusAnnotation                 = "replicator.v1.mittwald.de/push-status"
+)
+
+// ReplicatedFromLabel references the source of a push replica; replicas are only deleted if they carry it
+const ReplicatedFromLabel = "replicator.v1.mittwald.de/replicated-from"
+
+// ReplicatedFromKindLabel names the kind of the source of a push replica
+const ReplicatedFromKindLabel = "replicator.v1.mittwald.de/replicated-from-kind"
+
+// CreatedReplicaAnnotation marks push replicas that have been created by the replicator, as opposed to already
+// existing objects it replicated into; only the former are removed by garbage collection
+const CreatedReplicaAnnotation = "replicator.v1.mittwald.de/created-replica"
+
+// AnnotationPrefix is the common prefix of all annotations controlling the replicator; they are never propagated
+const AnnotationPrefix = "replicator.v1.mittwald.de/"
+
+// TemplateAnnotationPrefix is the prefix of annotations defining a template for the key named by the rest of the
+// annotation, e.g. "replicator.v1.mittwald.de/template-jdbc-url"
+const TemplateAnnotationPrefix = "replicator.v1.mittwald.de/template-"
+
+// Origins recorded in the ReplicatedKeySourcesAnnotation for keys that are not copied from a single source
+const (
+	RenderedKeyOrigin = "template"
+	MergedKeyOrigin   = "merged"
+	BundledKeyOrigin  = "bundle"
 )
@@ -0,0 +1,213 @@
+package common
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DependencyIndex keeps track of which objects are replicated from which source object (pull-based replication).
+// All methods are safe for concurrent use.
+type DependencyIndex struct {
+	lock sync.RWMutex
+
+	// dependents maps a source key to the keys of all objects replicated from it
+	dependents map[string]map[string]struct{}
+	// sources maps a dependent key to the keys of its sources, in order of increasing precedence
+	sources map[string][]string
+}
+
+// NewDependencyIndex creates an empty dependency index
+func NewDependencyIndex() *DependencyIndex {
+	return &DependencyIndex{
+		dependents: make(map[string]map[string]struct{}),
+		sources:    make(map[string][]string),
+	}
+}
+
+// Add registers dependent as being replicated from source alone, replacing any previous registration
+func (d *DependencyIndex) Add(source string, dependent string) {
+	d.SetSources(dependent, []string{source})
+}
+
+// SetSources registers dependent as being replicated from all of sources, replacing any previous registration
+func (d *DependencyIndex) SetSources(dependent string, sources []string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, previous := range d.sources[dependent] {
+		d.removeDependentFrom(previous, dependent)
+	}
+
+	for _, source := range sources {
+		if _, ok := d.dependents[source]; !ok {
+			d.dependents[source] = make(map[string]struct{})
+		}
+		d.dependents[source][dependent] = struct{}{}
+	}
+
+	d.sources[dependent] = append([]string(nil), sources...)
+}
+
+// RemoveDependent removes dependent from the index. It is a no-op if dependent is not known.
+func (d *DependencyIndex) RemoveDependent(dependent string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, source := range d.sources[dependent] {
+		d.removeDependentFrom(source, dependent)
+	}
+	delete(d.sources, dependent)
+}
+
+func (d *DependencyIndex) removeDependentFrom(source string, dependent string) {
+	dependents, ok := d.dependents[source]
+	if !ok {
+		return
+	}
+
+	delete(dependents, dependent)
+	if len(dependents) == 0 {
+		delete(d.dependents, source)
+	}
+}
+
+// Dependents returns the sorted keys of all objects replicated from source
+func (d *DependencyIndex) Dependents(source string) []string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	out := make([]string, 0, len(d.dependents[source]))
+	for dependent := range d.dependents[source] {
+		out = append(out, dependent)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// RemoveNamespace removes all dependents located in namespace from the index and returns their keys. Sources in
+// namespace are kept as long as dependents in other namespaces refer to them.
+func (d *DependencyIndex) RemoveNamespace(namespace string) []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	prefix := namespace + "/"
+	removed := make([]string, 0)
+	for dependent, sources := range d.sources {
+		if strings.HasPrefix(dependent, prefix) {
+			for _, source := range sources {
+				d.removeDependentFrom(source, dependent)
+			}
+			delete(d.sources, dependent)
+			removed = append(removed, dependent)
+		}
+	}
+	sort.Strings(removed)
+
+	return removed
+}
+
+// DependentsInNamespace returns the sorted keys of all dependents located in namespace
+func (d *DependencyIndex) DependentsInNamespace(namespace string) []string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	prefix := namespace + "/"
+	out := make([]string, 0)
+	for dependent := range d.sources {
+		if strings.HasPrefix(dependent, prefix) {
+			out = append(out, dependent)
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// Sources returns the keys of the objects dependent is replicated from, in order of increasing precedence
+func (d *DependencyIndex) Sources(dependent string) []string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return append([]string(nil), d.sources[dependent]...)
+}
+
+// Len returns the number of sources that have at least one dependent
+func (d *DependencyIndex) Len() int {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return len(d.dependents)
+}
+
+// KeySet is a set of object keys that is safe for concurrent use
+type KeySet struct {
+	lock sync.RWMutex
+	keys map[string]struct{}
+}
+
+// NewKeySet creates an empty key set
+func NewKeySet() *KeySet {
+	return &KeySet{keys: make(map[string]struct{})}
+}
+
+// Add adds key to the set
+func (s *KeySet) Add(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.keys[key] = struct{}{}
+}
+
+// Remove removes key from the set
+func (s *KeySet) Remove(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.keys, key)
+}
+
+// Has checks whether key is in the set
+func (s *KeySet) Has(key string) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, ok := s.keys[key]
+	return ok
+}
+
+// RemoveNamespace removes all keys located in namespace from the set
+func (s *KeySet) RemoveNamespace(namespace string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	prefix := namespace + "/"
+	for key := range s.keys {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.keys, key)
+		}
+	}
+}
+
+// List returns a sorted snapshot of all keys in the set
+func (s *KeySet) List() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	out := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// Len returns the number of keys in the set
+func (s *KeySet) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.keys)
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDependencyIndexAddAndLookup(t *testing.T) {
+	index := NewDependencyIndex()
+
+	index.Add("ns/source", "ns1/target")
+	index.Add("ns/source", "ns2/target")
+
+	assert.Equal(t, []string{"ns1/target", "ns2/target"}, index.Dependents("ns/source"))
+
+	assert.Equal(t, []string{"ns/source"}, index.Sources("ns1/target"))
+	assert.Equal(t, 1, index.Len())
+}
+
+func TestDependencyIndexAddReplacesPreviousSource(t *testing.T) {
+	index := NewDependencyIndex()
+
+	index.Add("ns/old", "ns1/target")
+	index.Add("ns/new", "ns1/target")
+
+	assert.Empty(t, index.Dependents("ns/old"))
+	assert.Equal(t, []string{"ns1/target"}, index.Dependents("ns/new"))
+	assert.Equal(t, 1, index.Len())
+}
+
+func TestDependencyIndexMultipleSources(t *testing.T) {
+	index := NewDependencyIndex()
+
+	index.SetSources("ns1/target", []string{"ns/base", "ns/overlay"})
+	index.Add("ns/base", "ns2/target")
+
+	assert.Equal(t, []string{"ns/base", "ns/overlay"}, index.Sources("ns1/target"))
+	assert.Equal(t, []string{"ns1/target", "ns2/target"}, index.Dependents("ns/base"))
+	assert.Equal(t, []string{"ns1/target"}, index.Dependents("ns/overlay"))
+
+	index.SetSources("ns1/target", []string{"ns/overlay"})
+	assert.Equal(t, []string{"ns2/target"}, index.Dependents("ns/base"))
+
+	index.RemoveDependent("ns1/target")
+	assert.Empty(t, index.Dependents("ns/overlay"))
+	assert.Equal(t, 1, index.Len())
+}
+
+func TestDependencyIndexRemoveDependent(t *testing.T) {
+	index := NewDependencyIndex()
+
+	index.Add("ns/source", "ns1/target")
+	index.RemoveDependent("ns1/target")
+	index.RemoveDependent("ns2/unknown")
+
+	assert.Empty(t, index.Sources("ns1/target"))
+	assert.Empty(t, index.Dependents("ns/source"))
+	assert.Equal(t, 0, index.Len())
+}
+
+func TestDependencyIndexConcurrentAccess(t *testing.T) {
+	index := NewDependencyIndex()
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			source := fmt.Sprintf("ns/source-%d", i%5)
+			dependent := fmt.Sprintf("ns-%d/target", i)
+
+			index.Add(source, dependent)
+			index.Dependents(source)
+			index.Sources(dependent)
+			index.Len()
+
+			if i%2 == 0 {
+				index.RemoveDependent(dependent)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	count := 0
+	for i := 0; i < 5; i++ {
+		count += len(index.Dependents(fmt.Sprintf("ns/source-%d", i)))
+	}
+	assert.Equal(t, 25, count)
+}
+
+func TestKeySetConcurrentAccess(t *testing.T) {
+	set := NewKeySet()
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			key := fmt.Sprintf("ns/key-%d", i)
+			set.Add(key)
+			set.Has(key)
+			set.List()
+
+			if i%2 == 0 {
+				set.Remove(key)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 25, set.Len())
+}
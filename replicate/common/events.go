@@ -0,0 +1,76 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the events recorded on source and target objects
+const (
+	ReasonReplicated          = "Replicated"
+	ReasonReplicationDenied   = "ReplicationDenied"
+	ReasonReplicationFailed   = "ReplicationFailed"
+	ReasonPushedToNamespaces  = "PushedToNamespaces"
+	ReasonCertificateOutdated = "CertificateOutdated"
+)
+
+const eventComponent = "kubernetes-replicator"
+
+var events eventRecorder
+
+// eventRecorder shares a single event broadcaster between all replicators
+type eventRecorder struct {
+	doOnce   sync.Once
+	recorder record.EventRecorder
+}
+
+// get returns the shared event recorder, creating it on first use
+func (e *eventRecorder) get(client kubernetes.Interface) record.EventRecorder {
+	e.doOnce.Do(func() {
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+
+		e.recorder = broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: eventComponent})
+	})
+
+	return e.recorder
+}
+
+// ReplicationDeniedError is returned when the source of a replication does not permit replicating into a target
+type ReplicationDeniedError struct {
+	message string
+}
+
+func newReplicationDeniedError(format string, args ...interface{}) error {
+	return &ReplicationDeniedError{message: fmt.Sprintf(format, args...)}
+}
+
+func (e *ReplicationDeniedError) Error() string {
+	return e.message
+}
+
+// IsReplicationDenied checks whether err was caused by a ReplicationDeniedError
+func IsReplicationDenied(err error) bool {
+	_, ok := errors.Cause(err).(*ReplicationDeniedError)
+	return ok
+}
+
+// recordEvent records an event on obj; objects that cannot be referenced are skipped
+func (r *GenericReplicator) recordEvent(obj interface{}, eventType string, reason string, messageFmt string, args ...interface{}) {
+	object, ok := obj.(runtime.Object)
+	if !ok || r.Recorder == nil {
+		log.WithField("kind", r.Kind).Debugf("not recording event %s on %T", reason, obj)
+		return
+	}
+
+	r.Recorder.Eventf(object, eventType, reason, messageFmt, args...)
+}
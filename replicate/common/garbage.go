@@ -0,0 +1,66 @@
+package common
+
+import (
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CollectGarbage deletes push replicas whose source no longer exists, or no longer targets their namespace under
+// their name. Only objects that have been created by the replicator as push replicas of its kind are considered, so
+// already existing objects that have merely been replicated into are never deleted. Replicas in namespaces that are
+// not known (yet) are left alone.
+func (r *GenericReplicator) CollectGarbage() {
+	logger := log.WithField("kind", r.Kind)
+
+	sources := make(map[string]metav1.Object)
+	for _, obj := range r.Store.List() {
+		source := MustGetObject(obj)
+		_, replicateTo := source.GetAnnotations()[ReplicateTo]
+		_, replicateToMatching := source.GetAnnotations()[ReplicateToMatching]
+		if replicateTo || replicateToMatching {
+			sources[SourceReference(MustGetKey(obj))] = source
+		}
+	}
+
+	for _, obj := range r.TargetStore.List() {
+		replica := MustGetObject(obj)
+		reference, ok := replica.GetLabels()[ReplicatedFromLabel]
+		if !ok || !r.isOwnReplica(replica) || !isCreatedReplica(replica) {
+			continue
+		}
+
+		namespaceObj, exists, err := namespaceWatcher.NamespaceStore.GetByKey(replica.GetNamespace())
+		if err != nil || !exists {
+			continue
+		}
+		namespace := namespaceObj.(*v1.Namespace)
+
+		if source, ok := sources[reference]; ok && r.targetsReplica(source, namespace, replica.GetName()) {
+			continue
+		}
+
+		logger.WithField("target", MustGetKey(obj)).
+			Infof("deleting orphaned replica %s %s: its source does not replicate it any more", r.Kind, MustGetKey(obj))
+		if err := r.UpdateFuncs.DeleteReplicatedResource(obj); err != nil {
+			logger.WithField("target", MustGetKey(obj)).WithError(err).
+				Warnf("could not delete orphaned replica %s %s: %v", r.Kind, MustGetKey(obj), err)
+		}
+	}
+}
+
+// targetsReplica checks whether source is replicated into namespace under the given name. Sources with invalid
+// annotations are assumed to still target all of their replicas.
+func (r *GenericReplicator) targetsReplica(source metav1.Object, namespace *v1.Namespace, name string) bool {
+	selector, err := NewNamespaceSelector(source.GetAnnotations(), ReplicateTo, ReplicateToMatching)
+	if err != nil {
+		return true
+	}
+
+	if len(r.getNamespacesToReplicate(source.GetNamespace(), selector, []v1.Namespace{*namespace})) == 0 {
+		return false
+	}
+
+	targetName, err := TargetName(source, namespace.Name)
+	return err != nil || targetName == name
+}
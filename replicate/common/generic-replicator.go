@@ -2,9 +2,9 @@ package common
 
 import (
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -12,10 +12,21 @@ import (
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// retryBaseDelay is the delay before the first retry of a failed event; it doubles with every further failure
+	retryBaseDelay = 500 * time.Millisecond
+	// retryMaxDelay caps the delay between two retries of the same event
+	retryMaxDelay = 5 * time.Minute
 )
 
 type ReplicatorConfig struct {
@@ -24,37 +35,109 @@ type ReplicatorConfig struct {
 	ResyncPeriod time.Duration
 	AllowAll     bool
 	Strict       bool
+	MaxRetries   int
 	ListFunc     cache.ListFunc
 	WatchFunc    cache.WatchFunc
+	PatchFunc    PatchFunc
 	ObjType      runtime.Object
+
+	// TargetListFunc, TargetWatchFunc and TargetObjType configure a separate informer for the targets of push-based
+	// replication, for replicators whose targets are of another kind than their sources. If they are not set,
+	// targets are looked up in the store of the sources.
+	TargetListFunc  cache.ListFunc
+	TargetWatchFunc cache.WatchFunc
+	TargetObjType   runtime.Object
+
+	// SourceKinds configures sources of other kinds that pull targets may be replicated from, keyed by the prefix
+	// naming the kind in ReplicateFromAnnotation (e.g. "secret" for "secret:<namespace>/<name>")
+	SourceKinds map[string]SourceKind
+
+	// Propagation selects the labels and annotations that are copied into push replicas, for replicators that
+	// support it
+	Propagation MetadataPropagation
 }
 
+// SourceKind configures an informer for sources of another kind than the replicator's own. The informer is only
+// started once a target refers to a source of that kind.
+type SourceKind struct {
+	ListFunc  cache.ListFunc
+	WatchFunc cache.WatchFunc
+	ObjType   runtime.Object
+}
+
+// sourceInformer caches sources of another kind
+type sourceInformer struct {
+	store      cache.Store
+	controller cache.Controller
+	once       sync.Once
+}
+
+// PatchFunc patches the object with the given namespace and name
+type PatchFunc func(namespace string, name string, pt types.PatchType, data []byte) (runtime.Object, error)
+
 type UpdateFuncs struct {
 	ReplicateDataFrom        func(source interface{}, target interface{}) error
 	ReplicateObjectTo        func(source interface{}, target *v1.Namespace) error
 	PatchDeleteDependent     func(sourceKey string, target interface{}) (interface{}, error)
 	DeleteReplicatedResource func(target interface{}) error
+
+	// ReleaseReplicatedResource removes the replicated data and the source reference from a push replica that already
+	// existed when it was first replicated into, and therefore must not be deleted along with its source.
+	ReleaseReplicatedResource func(target interface{}) error
+
+	// MergeDataFrom replicates the data of several sources, given in order of increasing precedence, into target. It
+	// is optional; replicators without it do not support more than one source per target.
+	MergeDataFrom func(sources []interface{}, target interface{}) error
+
+	// ObjectSynced and ObjectDeleted are optional hooks that are called after the current state of an object, or its
+	// deletion, has been processed, regardless of whether that succeeded
+	ObjectSynced  func(obj interface{})
+	ObjectDeleted func(obj interface{})
 }
 
 type GenericReplicator struct {
 	ReplicatorConfig
 	Store      cache.Store
 	Controller cache.Controller
+	Queue      workqueue.RateLimitingInterface
+
+	// TargetStore contains the targets of push-based replication; it is the same as Store unless a separate target
+	// informer is configured
+	TargetStore      cache.Store
+	TargetController cache.Controller
+
+	Recorder record.EventRecorder
 
-	DependencyMap map[string]map[string]interface{}
-	DependentMap  map[string]string
-	UpdateFuncs   UpdateFuncs
+	// Dependencies tracks which objects are replicated from which source via ReplicateFromAnnotation
+	Dependencies *DependencyIndex
+	UpdateFuncs  UpdateFuncs
 
-	ReplicateToList map[string]struct{}
+	// ReplicateToList contains the keys of all objects with ReplicateTo annotation
+	ReplicateToList *KeySet
+
+	// sourceInformers cache the sources of other kinds configured in SourceKinds
+	sourceInformers map[string]*sourceInformer
+
+	informerOnce sync.Once
+
+	// deleted holds the last known state of deleted objects until their deletion has been processed
+	deleted     map[string]interface{}
+	deletedLock sync.Mutex
 }
 
 // NewGenericReplicator creates a new generic replicator
 func NewGenericReplicator(config ReplicatorConfig) *GenericReplicator {
 	repl := GenericReplicator{
 		ReplicatorConfig: config,
-		DependencyMap:    make(map[string]map[string]interface{}),
-		DependentMap:     make(map[string]string),
-		ReplicateToList:  make(map[string]struct{}),
+		Dependencies:     NewDependencyIndex(),
+		ReplicateToList:  NewKeySet(),
+		sourceInformers:  make(map[string]*sourceInformer),
+		deleted:          make(map[string]interface{}),
+		Recorder:         events.get(config.Client),
+		Queue: workqueue.NewNamedRateLimitingQueue(
+			workqueue.NewItemExponentialFailureRateLimiter(retryBaseDelay, retryMaxDelay),
+			config.Kind,
+		),
 	}
 
 	store, controller := cache.NewInformer(
@@ -65,20 +148,117 @@ func NewGenericReplicator(config ReplicatorConfig) *GenericReplicator {
 		config.ObjType,
 		config.ResyncPeriod,
 		cache.ResourceEventHandlerFuncs{
-			AddFunc:    repl.ResourceAdded,
-			UpdateFunc: func(old interface{}, new interface{}) { repl.ResourceAdded(new) },
-			DeleteFunc: repl.ResourceDeleted,
+			AddFunc:    repl.enqueue,
+			UpdateFunc: func(old interface{}, new interface{}) { repl.enqueue(new) },
+			DeleteFunc: repl.enqueueDeleted,
 		},
 	)
 
 	namespaceWatcher.OnNamespaceAdded(config.Client, config.ResyncPeriod, repl.NamespaceAdded)
+	namespaceWatcher.OnNamespaceUpdated(config.Client, config.ResyncPeriod, repl.NamespaceUpdated)
+	namespaceWatcher.OnNamespaceDeleted(config.Client, config.ResyncPeriod, repl.NamespaceDeleted)
 
 	repl.Store = store
 	repl.Controller = controller
+	repl.TargetStore = store
+
+	if config.TargetObjType != nil {
+		repl.TargetStore, repl.TargetController = cache.NewInformer(
+			&cache.ListWatch{
+				ListFunc:  config.TargetListFunc,
+				WatchFunc: config.TargetWatchFunc,
+			},
+			config.TargetObjType,
+			config.ResyncPeriod,
+			cache.ResourceEventHandlerFuncs{
+				DeleteFunc: func(obj interface{}) {
+					if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+						obj = tombstone.Obj
+					}
+					repl.requeuePushSourcesOf(obj)
+				},
+			},
+		)
+	}
+
+	for prefix, kind := range config.SourceKinds {
+		prefix := prefix
+		enqueue := func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			repl.Queue.Add(prefix + ":" + MustGetKey(obj))
+		}
+
+		informer := sourceInformer{}
+		informer.store, informer.controller = cache.NewInformer(
+			&cache.ListWatch{
+				ListFunc:  kind.ListFunc,
+				WatchFunc: kind.WatchFunc,
+			},
+			kind.ObjType,
+			config.ResyncPeriod,
+			cache.ResourceEventHandlerFuncs{
+				AddFunc:    enqueue,
+				UpdateFunc: func(old interface{}, new interface{}) { enqueue(new) },
+				DeleteFunc: enqueue,
+			},
+		)
+		repl.sourceInformers[prefix] = &informer
+	}
 
 	return &repl
 }
 
+// sourceStore returns the store containing the source with the given location, and the key of the source within
+// that store. Locations of sources of another kind are prefixed with the kind, as in "secret:<namespace>/<name>".
+func (r *GenericReplicator) sourceStore(location string) (cache.Store, string, error) {
+	prefix, key, ok := sourceKindPrefix(location)
+	if !ok {
+		return r.Store, location, nil
+	}
+
+	informer, ok := r.sourceInformers[prefix]
+	if !ok {
+		return nil, "", errors.Errorf("replication of %s from %s is not supported", r.Kind, location)
+	}
+
+	informer.once.Do(func() {
+		log.WithField("kind", r.Kind).Infof("starting %s informer for sources of kind %s", r.Kind, prefix)
+		go informer.controller.Run(wait.NeverStop)
+	})
+
+	if !informer.controller.HasSynced() {
+		return nil, "", errors.Errorf("cache for sources of kind %s has not been synced yet", prefix)
+	}
+
+	return informer.store, key, nil
+}
+
+// sourceKindPrefix splits the kind prefix off a source location like "secret:<namespace>/<name>". Since names may
+// contain colons (as in "system:controller:foo") but namespaces may not, only a colon before the first slash
+// separates a prefix.
+func sourceKindPrefix(location string) (string, string, bool) {
+	i := strings.Index(location, ":")
+	if i < 0 || strings.Contains(location[:i], "/") {
+		return "", location, false
+	}
+
+	return location[:i], location[i+1:], true
+}
+
+// IsOtherSourceKind checks whether key is the key of a source of another kind configured in SourceKinds, as opposed
+// to the key of an object of the replicator's own kind
+func (r *GenericReplicator) IsOtherSourceKind(key string) bool {
+	prefix, _, ok := sourceKindPrefix(key)
+	if !ok {
+		return false
+	}
+
+	_, configured := r.sourceInformers[prefix]
+	return configured
+}
+
 // IsReplicationPermitted checks if replication is allowed in annotations of the source object
 // Returns true if replication is allowed. If replication is not allowed returns false with
 // error message
@@ -90,114 +270,285 @@ func (r *GenericReplicator) IsReplicationPermitted(object *metav1.ObjectMeta, so
 	// make sure source object allows replication
 	annotationAllowed, ok := sourceObject.Annotations[ReplicationAllowed]
 	if !ok {
-		return false, fmt.Errorf("source %s/%s does not allow replication. %s will not be replicated",
+		return false, newReplicationDeniedError("source %s/%s does not allow replication. %s will not be replicated",
 			sourceObject.Namespace, sourceObject.Name, object.Name)
 	}
 	annotationAllowedBool, err := strconv.ParseBool(annotationAllowed)
 
 	// check if source object allows replication
 	if err != nil || !annotationAllowedBool {
-		return false, fmt.Errorf("source %s/%s does not allow replication. %s will not be replicated",
+		return false, newReplicationDeniedError("source %s/%s does not allow replication. %s will not be replicated",
 			sourceObject.Namespace, sourceObject.Name, object.Name)
 	}
 
 	// check if the target namespace is permitted
-	annotationAllowedNamespaces, ok := sourceObject.Annotations[ReplicationAllowedNamespaces]
-	if !ok {
-		return false, fmt.Errorf(
-			"source %s/%s does not allow replication (%s annotation missing). %s will not be replicated",
-			sourceObject.Namespace, sourceObject.Name, ReplicationAllowedNamespaces, object.Name)
-	}
-	allowedNamespaces := strings.Split(annotationAllowedNamespaces, ",")
-	allowed := false
-	for _, ns := range allowedNamespaces {
-		ns := strings.TrimSpace(ns)
-
-		if matched, _ := regexp.MatchString(ns, object.Namespace); matched {
-			log.Tracef("Namespace '%s' matches '%s' -- allowing replication", object.Namespace, ns)
-			allowed = true
-			break
-		}
+	selector, err := NewNamespaceSelector(sourceObject.Annotations, ReplicationAllowedNamespaces, ReplicationAllowedNamespacesMatching)
+	if err != nil {
+		return false, newReplicationDeniedError("source %s/%s does not allow replication: %v. %s will not be replicated",
+			sourceObject.Namespace, sourceObject.Name, err, object.Name)
+	}
+	if selector == nil {
+		return false, newReplicationDeniedError(
+			"source %s/%s does not allow replication (%s or %s annotation missing). %s will not be replicated",
+			sourceObject.Namespace, sourceObject.Name, ReplicationAllowedNamespaces, ReplicationAllowedNamespacesMatching,
+			object.Name)
 	}
 
-	err = nil
-	if !allowed {
-		err = fmt.Errorf(
+	if !selector.Matches(namespaceWatcher.Namespace(object.Namespace)) {
+		return false, newReplicationDeniedError(
 			"source %s/%s does not allow replication in namespace %s. %s will not be replicated",
 			sourceObject.Namespace, sourceObject.Name, object.Namespace, object.Name)
 	}
-	return allowed, err
+
+	log.Tracef("Namespace '%s' matches '%s' -- allowing replication", object.Namespace, selector)
+	return true, nil
+}
+
+// Namespace returns the namespace with the given name from the shared namespace cache
+func (r *GenericReplicator) Namespace(name string) *v1.Namespace {
+	return namespaceWatcher.Namespace(name)
 }
 
 func (r *GenericReplicator) Synced() bool {
-	return r.Controller.HasSynced()
+	return r.Controller.HasSynced() && (r.TargetController == nil || r.TargetController.HasSynced())
+}
+
+// StartInformer starts the informer in the background. Calling it more than once has no effect.
+func (r *GenericReplicator) StartInformer() {
+	r.informerOnce.Do(func() {
+		log.WithField("kind", r.Kind).Infof("starting %s informer", r.Kind)
+		go r.Controller.Run(wait.NeverStop)
+
+		if r.TargetController != nil {
+			go r.TargetController.Run(wait.NeverStop)
+		}
+	})
 }
 
+// Run starts the informer and processes queued events until the process terminates. Orphaned push replicas are
+// deleted once per resync period.
 func (r *GenericReplicator) Run() {
+	defer r.Queue.ShutDown()
+
 	log.WithField("kind", r.Kind).Infof("running %s controller", r.Kind)
-	r.Controller.Run(wait.NeverStop)
+	r.StartInformer()
+
+	if !cache.WaitForCacheSync(wait.NeverStop, r.Synced, namespaceWatcher.NamespaceController.HasSynced) {
+		log.WithField("kind", r.Kind).Errorf("timed out waiting for %s cache to sync", r.Kind)
+		return
+	}
+
+	go wait.Until(r.CollectGarbage, r.ResyncPeriod, wait.NeverStop)
+	wait.Until(r.runWorker, time.Second, wait.NeverStop)
+}
+
+// enqueue adds the key of an added or updated object to the work queue
+func (r *GenericReplicator) enqueue(obj interface{}) {
+	r.Queue.Add(MustGetKey(obj))
+}
+
+// enqueueDeleted remembers the last known state of a deleted object and adds its key to the work queue
+func (r *GenericReplicator) enqueueDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	key := MustGetKey(obj)
+
+	r.deletedLock.Lock()
+	r.deleted[key] = obj
+	r.deletedLock.Unlock()
+
+	r.Queue.Add(key)
+}
+
+// deletedObject returns the last known state of a deleted object that has not been processed yet
+func (r *GenericReplicator) deletedObject(key string) (interface{}, bool) {
+	r.deletedLock.Lock()
+	defer r.deletedLock.Unlock()
+
+	obj, ok := r.deleted[key]
+	return obj, ok
+}
+
+func (r *GenericReplicator) forgetDeletedObject(key string) {
+	r.deletedLock.Lock()
+	defer r.deletedLock.Unlock()
+
+	delete(r.deleted, key)
 }
 
-// NamespaceAdded replicates resources with ReplicateTo annotation into newly created namespaces
+func (r *GenericReplicator) runWorker() {
+	for r.processNextItem() {
+	}
+}
+
+// processNextItem takes the next key from the work queue and synchronizes it. It returns false once the queue
+// has been shut down.
+func (r *GenericReplicator) processNextItem() bool {
+	key, shutdown := r.Queue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.Queue.Done(key)
+
+	err := r.syncKey(key.(string))
+	r.handleErr(err, key)
+
+	pushSources.WithLabelValues(r.Kind).Set(float64(r.ReplicateToList.Len()))
+	pullSources.WithLabelValues(r.Kind).Set(float64(r.Dependencies.Len()))
+
+	return true
+}
+
+// handleErr re-queues failed keys with exponential backoff until MaxRetries is exceeded. A negative MaxRetries
+// retries forever.
+func (r *GenericReplicator) handleErr(err error, key interface{}) {
+	logger := log.WithField("kind", r.Kind).WithField("resource", key)
+
+	if err == nil {
+		r.Queue.Forget(key)
+		return
+	}
+
+	if r.MaxRetries < 0 || r.Queue.NumRequeues(key) < r.MaxRetries {
+		logger.WithError(err).Warnf("Error syncing %s %s, retrying: %v", r.Kind, key, err)
+		r.Queue.AddRateLimited(key)
+		return
+	}
+
+	logger.WithError(err).Errorf("Dropping %s %s out of the queue after %d retries: %v", r.Kind, key, r.MaxRetries, err)
+	r.Queue.Forget(key)
+	r.forgetDeletedObject(key.(string))
+}
+
+// syncKey processes the current state of the object with the given key: objects still present in the store are
+// treated as added or updated, all others as deleted.
+func (r *GenericReplicator) syncKey(key string) error {
+	obj, exists, err := r.Store.GetByKey(key)
+	if err != nil {
+		return errors.Wrapf(err, "Failed fetching %s %s from store: %v", r.Kind, key, err)
+	}
+
+	if exists {
+		r.forgetDeletedObject(key)
+		err := r.ResourceAdded(obj)
+		if r.UpdateFuncs.ObjectSynced != nil {
+			r.UpdateFuncs.ObjectSynced(obj)
+		}
+		return err
+	}
+
+	// sources of another kind are only tracked as dependencies
+	if r.IsOtherSourceKind(key) {
+		return r.syncDependents(key)
+	}
+
+	deleted, ok := r.deletedObject(key)
+	if !ok {
+		return nil
+	}
+
+	err = r.ResourceDeleted(deleted)
+	if r.UpdateFuncs.ObjectDeleted != nil {
+		r.UpdateFuncs.ObjectDeleted(deleted)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.forgetDeletedObject(key)
+	return nil
+}
+
+// NamespaceAdded queues all resources with ReplicateTo annotation so that they get replicated into newly created
+// namespaces
 func (r *GenericReplicator) NamespaceAdded(ns *v1.Namespace) {
 	logger := log.WithField("kind", r.Kind).WithField("target", ns.Name)
-	for sourceKey := range r.ReplicateToList {
-		obj, exists, err := r.Store.GetByKey(sourceKey)
-		if err != nil {
-			log.WithError(err).Errorf("Failed fetching %s %s from store: %+v", r.Kind, sourceKey, err)
-			continue
-		} else if !exists {
-			log.Warnf("Object %s %s not found in store -- cannot replicate it to a new namespace", r.Kind, sourceKey)
-			continue
-		}
+	for _, sourceKey := range r.ReplicateToList.List() {
+		logger.WithField("source", sourceKey).Debugf("Queueing %s %s for new namespace %s", r.Kind, sourceKey, ns.Name)
+		r.Queue.Add(sourceKey)
+	}
+}
 
-		objectMeta := MustGetObject(obj)
-		replicatedList := make([]string, 0)
-		namespacePatterns, found := objectMeta.GetAnnotations()[ReplicateTo]
-		if found {
-			if err := r.replicateResourceToMatchingNamespaces(obj, namespacePatterns, []v1.Namespace{*ns}); err != nil {
-				logger.
-					WithError(err).
-					Errorf("Failed replicating the resource to the new namespace %s: %v", ns.Name, err)
-			} else {
-				replicatedList = append(replicatedList, ns.Name)
-			}
-			key := MustGetKey(objectMeta)
-			logger.WithField("source", key).Infof("Replicated %s to: %v", key, replicatedList)
+// NamespaceUpdated re-evaluates all replications that may depend on the labels or annotations of the namespace, if
+// they changed: resources with ReplicateTo or ReplicateToMatching annotation and replication targets within the
+// namespace. Replicas of resources whose annotations no longer select the namespace are deleted.
+func (r *GenericReplicator) NamespaceUpdated(old *v1.Namespace, new *v1.Namespace) {
+	if labels.Equals(old.Labels, new.Labels) && labels.Equals(old.Annotations, new.Annotations) {
+		return
+	}
+
+	logger := log.WithField("kind", r.Kind).WithField("target", new.Name)
+	for _, sourceKey := range r.ReplicateToList.List() {
+		if err := r.deleteUnselectedReplica(sourceKey, old, new); err != nil {
+			logger.WithField("source", sourceKey).WithError(err).Warnf("Could not delete replica of %s %s in namespace %s: %v",
+				r.Kind, sourceKey, new.Name, err)
 		}
+
+		logger.WithField("source", sourceKey).Debugf("Queueing %s %s for relabeled namespace %s", r.Kind, sourceKey, new.Name)
+		r.Queue.Add(sourceKey)
+	}
+
+	for _, dependentKey := range r.Dependencies.DependentsInNamespace(new.Name) {
+		logger.WithField("resource", dependentKey).Debugf("Queueing %s %s in relabeled namespace %s", r.Kind, dependentKey, new.Name)
+		r.Queue.Add(dependentKey)
+	}
+}
+
+// deleteUnselectedReplica deletes the replica of the resource with the given key in the namespace, if the namespace
+// was selected by the resource's ReplicateTo or ReplicateToMatching annotation before it was relabeled, but is not
+// selected any more
+func (r *GenericReplicator) deleteUnselectedReplica(sourceKey string, old *v1.Namespace, new *v1.Namespace) error {
+	source, exists, err := r.Store.GetByKey(sourceKey)
+	if err != nil {
+		return errors.Wrapf(err, "Failed fetching %s %s from store: %v", r.Kind, sourceKey, err)
+	} else if !exists {
+		return nil
+	}
+
+	selector, err := NewNamespaceSelector(MustGetObject(source).GetAnnotations(), ReplicateTo, ReplicateToMatching)
+	if err != nil || selector == nil {
+		// invalid selectors are reported when the resource itself is synced
+		return nil
+	}
+
+	if !selector.Matches(old) || selector.Matches(new) {
+		return nil
+	}
+
+	log.WithField("kind", r.Kind).WithField("source", sourceKey).WithField("target", new.Name).
+		Infof("namespace %s does not match [%s] any more, deleting replica of %s %s", new.Name, selector, r.Kind, sourceKey)
+
+	return r.DeleteResource(*new, source)
+}
+
+// NamespaceDeleted forgets all replication targets in a deleted namespace and queues all resources with ReplicateTo
+// or ReplicateToMatching annotation, so that their push status gets updated
+func (r *GenericReplicator) NamespaceDeleted(ns *v1.Namespace) {
+	logger := log.WithField("kind", r.Kind).WithField("target", ns.Name)
+
+	removed := r.Dependencies.RemoveNamespace(ns.Name)
+	r.ReplicateToList.RemoveNamespace(ns.Name)
+	logger.Debugf("Removed %d %s dependents in deleted namespace %s", len(removed), r.Kind, ns.Name)
+
+	for _, sourceKey := range r.ReplicateToList.List() {
+		r.Queue.Add(sourceKey)
 	}
 }
 
 // ResourceAdded checks resources with ReplicateTo or ReplicateFromAnnotation annotation
-func (r *GenericReplicator) ResourceAdded(obj interface{}) {
+func (r *GenericReplicator) ResourceAdded(obj interface{}) error {
 	objectMeta := MustGetObject(obj)
 	sourceKey := MustGetKey(objectMeta)
 	logger := log.WithField("kind", r.Kind).WithField("resource", sourceKey)
 
-	replicas, ok := r.DependencyMap[sourceKey]
-	if ok {
+	var result error
+
+	if replicas := r.Dependencies.Dependents(sourceKey); len(replicas) > 0 {
 		logger.Debugf("objectMeta %s has %d dependents", sourceKey, len(replicas))
 		if err := r.updateDependents(obj, replicas); err != nil {
-			logger.WithError(err).
-				Errorf("Failed to update cache for %s: %v", MustGetKey(objectMeta), err)
-		}
-	}
-	source, ok := r.DependentMap[sourceKey]
-	if ok {
-		logger.Debugf("objectMeta %s has source %s", sourceKey, source)
-
-		sourceObject, exists, err := r.Store.GetByKey(source)
-		if err != nil {
-			logger.Debugf("could not get source %s %s: %s", r.Kind, source, err)
-			return
-		} else if !exists {
-			logger.Debugf("could not get source %s %s: does not exist", r.Kind, source)
-			return
-		}
-		targetMap := map[string]interface{}{MustGetKey(obj): ""}
-		if err := r.updateDependents(sourceObject, targetMap); err != nil {
-			logger.WithError(err).
-				Errorf("Failed to update cache for %s: %v", MustGetKey(objectMeta), err)
+			result = multierror.Append(result, errors.Wrapf(err, "Failed to update dependents of %s: %v", sourceKey, err))
 		}
 	}
 
@@ -205,136 +556,333 @@ func (r *GenericReplicator) ResourceAdded(obj interface{}) {
 	source, replicateFrom := objectMeta.GetAnnotations()[ReplicateFromAnnotation]
 	if replicateFrom {
 		if err := r.resourceAddedReplicateFrom(source, obj); err != nil {
-			logger.WithError(err).Errorf(
-				"Could not copy %s -> %s: %v",
-				source, MustGetKey(objectMeta), err,
-			)
+			result = multierror.Append(result, errors.Wrapf(err, "Could not copy %s -> %s: %v", source, sourceKey, err))
 		}
-		return
+		return result
 	}
 
-	// Match resources with "replicate-to" annotation
-	namespacePatterns, replicateTo := objectMeta.GetAnnotations()[ReplicateTo]
-	if replicateTo {
-		r.ReplicateToList[sourceKey] = struct{}{}
-
-		if list, err := r.Client.CoreV1().Namespaces().List(metav1.ListOptions{}); err != nil {
-			logger.WithError(err).Errorf("Failed to list namespaces: %v", err)
-			return
-		} else if err := r.replicateResourceToMatchingNamespaces(obj, namespacePatterns, list.Items); err != nil {
-			logger.
-				WithError(err).
-				Errorf(
-					"Could not replicate %s to other namespaces: %+v",
-					MustGetKey(objectMeta), err,
-				)
+	r.Dependencies.RemoveDependent(sourceKey)
+
+	// Match resources with "replicate-to" or "replicate-to-matching" annotation
+	selector, err := NewNamespaceSelector(objectMeta.GetAnnotations(), ReplicateTo, ReplicateToMatching)
+	switch {
+	case err != nil:
+		r.ReplicateToList.Add(sourceKey)
+		result = multierror.Append(result, errors.Wrapf(err, "Could not replicate %s to other namespaces: %v", sourceKey, err))
+	case selector != nil:
+		r.ReplicateToList.Add(sourceKey)
+
+		if err := r.replicateResourceToMatchingNamespaces(obj, selector, namespaceWatcher.Namespaces()); err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "Could not replicate %s to other namespaces: %v", sourceKey, err))
 		}
-		return
-	} else {
-		delete(r.ReplicateToList, sourceKey)
+	default:
+		r.ReplicateToList.Remove(sourceKey)
 	}
+
+	return result
 }
 
-// resourceAddedReplicateFrom replicates resources with ReplicateFromAnnotation
-func (r *GenericReplicator) resourceAddedReplicateFrom(sourceLocation string, target interface{}) error {
+// resourceAddedReplicateFrom replicates resources with ReplicateFromAnnotation. The annotation may list several
+// sources, in order of increasing precedence.
+func (r *GenericReplicator) resourceAddedReplicateFrom(sourceLocations string, target interface{}) error {
 	cacheKey := MustGetKey(target)
 
-	logger := log.WithField("kind", r.Kind).WithField("source", sourceLocation).WithField("target", cacheKey)
-	logger.Debugf("%s %s is replicated from %s", r.Kind, cacheKey, sourceLocation)
-	v := strings.SplitN(sourceLocation, "/", 2)
+	logger := log.WithField("kind", r.Kind).WithField("source", sourceLocations).WithField("target", cacheKey)
+	logger.Debugf("%s %s is replicated from %s", r.Kind, cacheKey, sourceLocations)
 
-	if len(v) < 2 {
-		return errors.Errorf("Invalid source location expected '<namespace>/<name>', got '%s'", sourceLocation)
+	locations, err := parseSourceLocations(sourceLocations)
+	if err != nil {
+		r.setReplicationStatus(target, ReplicationStatus{Status: StatusError, Message: err.Error()})
+		return err
+	}
+
+	for _, location := range locations {
+		if prefix, _, ok := sourceKindPrefix(location); ok {
+			if _, ok := r.sourceInformers[prefix]; !ok {
+				err := errors.Errorf("replication of %s from %s is not supported", r.Kind, location)
+				r.setReplicationStatus(target, ReplicationStatus{Status: StatusError, Message: err.Error()})
+				return err
+			}
+		}
+	}
+
+	r.Dependencies.SetSources(cacheKey, locations)
+
+	return r.replicateFromSources(target)
+}
+
+// parseSourceLocations splits the value of a ReplicateFromAnnotation into the keys of the sources
+func parseSourceLocations(sourceLocations string) ([]string, error) {
+	locations := make([]string, 0)
+	for _, location := range strings.Split(sourceLocations, ",") {
+		location = strings.TrimSpace(location)
+		if location == "" {
+			continue
+		}
+
+		if v := strings.SplitN(location, "/", 2); len(v) < 2 {
+			return nil, errors.Errorf("Invalid source location expected '<namespace>/<name>', got '%s'", location)
+		}
+		locations = append(locations, location)
 	}
 
-	if _, ok := r.DependencyMap[sourceLocation]; !ok {
-		r.DependencyMap[sourceLocation] = make(map[string]interface{})
+	if len(locations) == 0 {
+		return nil, errors.Errorf("Invalid source location expected '<namespace>/<name>', got '%s'", sourceLocations)
 	}
 
-	r.DependencyMap[sourceLocation][cacheKey] = nil
+	return locations, nil
+}
+
+// existingSources looks up the sources of dependent in the store. Sources that do not exist are skipped and
+// returned as missing.
+func (r *GenericReplicator) existingSources(dependent string) ([]interface{}, []string, error) {
+	sources := make([]interface{}, 0)
+	missing := make([]string, 0)
+
+	for _, location := range r.Dependencies.Sources(dependent) {
+		store, key, err := r.sourceStore(location)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sourceObject, exists, err := store.GetByKey(key)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "Could not get source %s: %v", location, err)
+		} else if !exists {
+			missing = append(missing, location)
+			continue
+		}
 
-	if _, ok := r.DependentMap[cacheKey]; !ok {
-		r.DependentMap[cacheKey] = sourceLocation
+		sources = append(sources, sourceObject)
 	}
 
-	sourceObject, exists, err := r.Store.GetByKey(sourceLocation)
+	return sources, missing, nil
+}
+
+// replicateFromSources replicates the data of all existing sources of target into target. Missing sources are
+// skipped, unless all sources are missing.
+func (r *GenericReplicator) replicateFromSources(target interface{}) error {
+	cacheKey := MustGetKey(target)
+	logger := log.WithField("kind", r.Kind).WithField("target", cacheKey)
+
+	sources, missing, err := r.existingSources(cacheKey)
 	if err != nil {
-		return errors.Wrapf(err, "Could not get source %s: %v", sourceLocation, err)
-	} else if !exists {
-		return errors.Errorf("Could not get source %s: does not exist", sourceLocation)
+		return err
+	}
+
+	if len(sources) == 0 {
+		err := errors.Errorf("Could not get source %s: does not exist", strings.Join(missing, ", "))
+		r.setReplicationStatus(target, ReplicationStatus{Status: StatusSourceMissing, Message: err.Error()})
+		return err
+	}
+
+	if len(missing) > 0 {
+		logger.Infof("skipping missing sources %s of %s %s", strings.Join(missing, ", "), r.Kind, cacheKey)
 	}
 
-	if err := r.UpdateFuncs.ReplicateDataFrom(sourceObject, target); err != nil {
+	if err := r.replicateDataFrom(sources, target); err != nil {
 		return errors.Wrapf(err, "Failed to replicate %s target %s -> %s: %v",
-			r.Kind, MustGetKey(sourceObject), cacheKey, err,
+			r.Kind, strings.Join(keysOf(sources), ", "), cacheKey, err,
 		)
 	}
 
 	return nil
 }
 
+func keysOf(objects []interface{}) []string {
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		keys = append(keys, MustGetKey(obj))
+	}
+
+	return keys
+}
+
 // resourceAddedReplicateFrom replicates resources with ReplicateTo annotation
-func (r *GenericReplicator) replicateResourceToMatchingNamespaces(obj interface{}, nsPatternList string, namespaceList []v1.Namespace) error {
+func (r *GenericReplicator) replicateResourceToMatchingNamespaces(obj interface{}, selector *NamespaceSelector, namespaceList []v1.Namespace) error {
 	cacheKey := MustGetKey(obj)
 	logger := log.WithField("kind", r.Kind).WithField("source", cacheKey)
 
-	logger.Infof("%s %s to be replicated to: [%s]", r.Kind, cacheKey, nsPatternList)
+	logger.Infof("%s %s to be replicated to: [%s]", r.Kind, cacheKey, selector)
+
+	replicateTo := r.getNamespacesToReplicate(MustGetObject(obj).GetNamespace(), selector, namespaceList)
 
-	replicateTo := r.getNamespacesToReplicate(MustGetObject(obj).GetNamespace(), nsPatternList, namespaceList)
+	replicated, err := r.replicateResourceToNamespaces(obj, replicateTo)
+	r.setPushStatus(obj, pushStatusFor(replicateTo, replicated))
 
-	if replicated, err := r.replicateResourceToNamespaces(obj, replicateTo); err != nil {
+	if err != nil {
 		return errors.Wrapf(err, "Replicated %s to %d out of %d namespaces: %v out of %v - %+v",
 			cacheKey, len(replicated), len(replicateTo), replicated, replicateTo, err,
 		)
 	}
 
+	lastSuccessfulSync.WithLabelValues(r.Kind, cacheKey).SetToCurrentTime()
+
 	return nil
 }
 
+// pushStatusFor summarizes into which of the targeted namespaces an object could be replicated
+func pushStatusFor(targets []v1.Namespace, replicatedTo []v1.Namespace) PushStatus {
+	status := PushStatus{ReplicatedTo: make([]string, 0), Failed: make([]string, 0)}
+	succeeded := make(map[string]struct{})
+
+	for _, namespace := range replicatedTo {
+		status.ReplicatedTo = append(status.ReplicatedTo, namespace.Name)
+		succeeded[namespace.Name] = struct{}{}
+	}
+
+	for _, namespace := range targets {
+		if _, ok := succeeded[namespace.Name]; !ok {
+			status.Failed = append(status.Failed, namespace.Name)
+		}
+	}
+
+	return status
+}
+
 // getNamespacesToReplicate will check the provided filters and create a list of namespace into with to replicate the
 // given object.
-func (r *GenericReplicator) getNamespacesToReplicate(myNs string, patterns string, namespaces []v1.Namespace) []v1.Namespace {
+func (r *GenericReplicator) getNamespacesToReplicate(myNs string, selector *NamespaceSelector, namespaces []v1.Namespace) []v1.Namespace {
 
 	replicateTo := make([]v1.Namespace, 0)
-	for _, namespace := range namespaces {
-		for _, ns := range StringToPatternList(patterns) {
-			if matched := ns.MatchString(namespace.Name); matched {
-				if namespace.Name == myNs {
-					// Don't replicate upon itself
-					continue
-				}
-				replicateTo = append(replicateTo, namespace)
-				break
-
-			}
+	for i := range namespaces {
+		if namespaces[i].Name == myNs {
+			// Don't replicate upon itself
+			continue
+		}
+		if namespaces[i].Status.Phase == v1.NamespaceTerminating {
+			// Objects cannot be created in terminating namespaces
+			continue
+		}
+		if selector.Matches(&namespaces[i]) {
+			replicateTo = append(replicateTo, namespaces[i])
 		}
 	}
 	return replicateTo
 }
 
+// replicateDataFrom calls UpdateFuncs.ReplicateDataFrom (or UpdateFuncs.MergeDataFrom for more than one source) and
+// records the outcome in the replication metrics and as event on the target
+func (r *GenericReplicator) replicateDataFrom(sources []interface{}, target interface{}) error {
+	sourceKeys := keysOf(sources)
+	sourceKey := strings.Join(sourceKeys, ", ")
+	targetKey := MustGetKey(target)
+	versionBefore := resourceVersion(r.Store, targetKey)
+
+	var err error
+	switch {
+	case len(sources) == 1:
+		err = r.UpdateFuncs.ReplicateDataFrom(sources[0], target)
+	case r.UpdateFuncs.MergeDataFrom != nil:
+		err = r.UpdateFuncs.MergeDataFrom(sources, target)
+	default:
+		err = errors.Errorf("replication of %s %s from more than one source is not supported", r.Kind, targetKey)
+	}
+	observeReplication(r.Kind, ModePull, err)
+
+	switch {
+	case IsReplicationDenied(err):
+		r.recordEvent(target, v1.EventTypeWarning, ReasonReplicationDenied,
+			"Replication from %s %s denied: %v", r.Kind, sourceKey, err)
+	case err != nil:
+		r.recordEvent(target, v1.EventTypeWarning, ReasonReplicationFailed,
+			"Replication from %s %s failed: %v", r.Kind, sourceKey, err)
+	default:
+		for _, key := range sourceKeys {
+			lastSuccessfulSync.WithLabelValues(r.Kind, key).SetToCurrentTime()
+		}
+		if resourceVersion(r.Store, targetKey) != versionBefore {
+			r.recordEvent(target, v1.EventTypeNormal, ReasonReplicated, "Replicated from %s %s", r.Kind, sourceKey)
+		}
+	}
+
+	r.setReplicationStatus(target, replicationStatusFor(err, fmt.Sprintf("Replicated from %s", sourceKey)))
+
+	return err
+}
+
+// replicateObjectTo calls UpdateFuncs.ReplicateObjectTo and records the outcome in the replication metrics and as
+// event on the target. It reports whether the target was created or updated.
+func (r *GenericReplicator) replicateObjectTo(source interface{}, target *v1.Namespace) (bool, error) {
+	sourceKey := MustGetKey(source)
+
+	targetName, err := TargetName(MustGetObject(source), target.Name)
+	if err != nil {
+		observeReplication(r.Kind, ModePush, err)
+		r.recordEvent(source, v1.EventTypeWarning, ReasonReplicationFailed,
+			"Replication to namespace %s failed: %v", target.Name, err)
+		return false, err
+	}
+
+	targetKey := fmt.Sprintf("%s/%s", target.Name, targetName)
+	versionBefore := resourceVersion(r.TargetStore, targetKey)
+
+	err = r.UpdateFuncs.ReplicateObjectTo(source, target)
+	observeReplication(r.Kind, ModePush, err)
+
+	if err != nil {
+		if targetObject, exists, _ := r.TargetStore.GetByKey(targetKey); exists {
+			r.recordEvent(targetObject, v1.EventTypeWarning, ReasonReplicationFailed,
+				"Replication from %s %s failed: %v", r.Kind, sourceKey, err)
+		}
+		r.recordEvent(source, v1.EventTypeWarning, ReasonReplicationFailed,
+			"Replication to namespace %s failed: %v", target.Name, err)
+		return false, err
+	}
+
+	targetObject, exists, _ := r.TargetStore.GetByKey(targetKey)
+	if !exists || resourceVersion(r.TargetStore, targetKey) == versionBefore {
+		return false, nil
+	}
+
+	r.recordEvent(targetObject, v1.EventTypeNormal, ReasonReplicated, "Replicated from %s %s", r.Kind, sourceKey)
+	return true, nil
+}
+
+// resourceVersion returns the resource version of the object with the given key in store, or an empty string if the
+// object does not exist
+func resourceVersion(store cache.Store, key string) string {
+	obj, exists, err := store.GetByKey(key)
+	if err != nil || !exists {
+		return ""
+	}
+
+	return MustGetObject(obj).GetResourceVersion()
+}
+
 // replicateResourceToNamespaces will replicate the given object into target namespaces. It will return a list of
 // Namespaces it was successful in replicating into
 func (r *GenericReplicator) replicateResourceToNamespaces(obj interface{}, targets []v1.Namespace) (replicatedTo []v1.Namespace, err error) {
 	cacheKey := MustGetKey(obj)
+	updated := make([]string, 0)
 
 	for _, namespace := range targets {
-		if err := r.UpdateFuncs.ReplicateObjectTo(obj, &namespace); err != nil {
-			err = multierror.Append(errors.Wrapf(err, "Failed to replicate %s %s -> %s: %v",
-				r.Kind, cacheKey, namespace.Name, err,
+		if changed, innerErr := r.replicateObjectTo(obj, &namespace); innerErr != nil {
+			err = multierror.Append(err, errors.Wrapf(innerErr, "Failed to replicate %s %s -> %s: %v",
+				r.Kind, cacheKey, namespace.Name, innerErr,
 			))
 		} else {
 			replicatedTo = append(replicatedTo, namespace)
+			if changed {
+				updated = append(updated, namespace.Name)
+			}
 		}
 	}
 
+	if len(updated) > 0 {
+		r.recordEvent(obj, v1.EventTypeNormal, ReasonPushedToNamespaces,
+			"Replicated to namespaces: %s", strings.Join(updated, ", "))
+	}
+
 	return
 }
 
-func (r *GenericReplicator) updateDependents(obj interface{}, dependents map[string]interface{}) error {
+// updateDependents replicates obj into all of its dependents, together with their other sources
+func (r *GenericReplicator) updateDependents(obj interface{}, dependents []string) error {
 	cacheKey := MustGetKey(obj)
 	logger := log.WithField("kind", r.Kind).WithField("source", cacheKey)
 
-	for dependentKey := range dependents {
+	for _, dependentKey := range dependents {
 		logger.Infof("updating dependent %s %s -> %s", r.Kind, cacheKey, dependentKey)
 
 		targetObject, exists, err := r.Store.GetByKey(dependentKey)
@@ -346,7 +894,7 @@ func (r *GenericReplicator) updateDependents(obj interface{}, dependents map[str
 			continue
 		}
 
-		if err := r.UpdateFuncs.ReplicateDataFrom(obj, targetObject); err != nil {
+		if err := r.replicateFromSources(targetObject); err != nil {
 			return errors.WithStack(err)
 		}
 	}
@@ -369,93 +917,180 @@ func (r *GenericReplicator) ObjectFromStore(key string) (interface{}, error) {
 }
 
 // ResourceDeleted watches for the deletion of resources
-func (r *GenericReplicator) ResourceDeleted(source interface{}) {
+func (r *GenericReplicator) ResourceDeleted(source interface{}) error {
 	sourceKey := MustGetKey(source)
 	logger := log.WithField("kind", r.Kind).WithField("source", sourceKey)
 	logger.Debugf("Deleting %s %s", r.Kind, sourceKey)
 
-	r.ResourceDeletedReplicateTo(source)
-	r.ResourceDeletedReplicateFrom(source)
+	var result error
+
+	if err := r.ResourceDeletedReplicateTo(source); err != nil {
+		result = multierror.Append(result, err)
+	}
+	if err := r.ResourceDeletedReplicateFrom(source); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	r.ReplicateToList.Remove(sourceKey)
+	r.Dependencies.RemoveDependent(sourceKey)
+	lastSuccessfulSync.DeleteLabelValues(r.Kind, sourceKey)
 
-	delete(r.ReplicateToList, sourceKey)
+	r.requeuePushSourcesOf(source)
 
+	return result
 }
 
-func (r *GenericReplicator) ResourceDeletedReplicateTo(source interface{}) {
-	sourceKey := MustGetKey(source)
-	logger := log.WithField("kind", r.Kind).WithField("source", sourceKey)
-	objMeta := MustGetObject(source)
-	namespaceList, replicateTo := objMeta.GetAnnotations()[ReplicateTo]
-	if replicateTo {
-		filters := strings.Split(namespaceList, ",")
-		list, err := r.Client.CoreV1().Namespaces().List(metav1.ListOptions{})
-		if err != nil {
-			err = errors.Wrapf(err, "Failed to list namespaces: %v", err)
-			logger.WithError(err).Errorf("Could not get namespaces: %+v", err)
-		} else {
-			r.DeleteResources(source, list, filters)
-		}
+// requeuePushSourcesOf queues all resources with ReplicateTo or ReplicateToMatching annotation that the deleted
+// object may have been replicated from, so that deleted replicas get recreated
+func (r *GenericReplicator) requeuePushSourcesOf(deleted interface{}) {
+	for _, sourceKey := range r.PushSourcesOf(deleted) {
+		log.WithField("kind", r.Kind).WithField("source", sourceKey).
+			Debugf("Queueing %s %s since its replica %s was deleted", r.Kind, sourceKey, MustGetKey(deleted))
+		r.Queue.Add(sourceKey)
 	}
 }
 
-func (r *GenericReplicator) DeleteResources(source interface{}, list *v1.NamespaceList, filters []string) {
-	for _, namespace := range list.Items {
-		for _, ns := range filters {
-			ns = strings.TrimSpace(ns)
-			if matched, _ := regexp.MatchString(ns, namespace.Name); matched {
-				r.DeleteResource(namespace, source)
-			}
+// PushSourcesOf returns the keys of all resources with ReplicateTo or ReplicateToMatching annotation that obj may
+// have been replicated from. Objects that are no push replicas have none.
+func (r *GenericReplicator) PushSourcesOf(obj interface{}) []string {
+	objectMeta := MustGetObject(obj)
+	if _, replicated := objectMeta.GetAnnotations()[ReplicatedFromVersionAnnotation]; !replicated {
+		return nil
+	}
+
+	sources := make([]string, 0)
+	for _, sourceKey := range r.ReplicateToList.List() {
+		if sourceKey == MustGetKey(obj) {
+			continue
+		}
+
+		source, exists, err := r.Store.GetByKey(sourceKey)
+		if err != nil || !exists {
+			continue
+		}
+
+		if name, err := TargetName(MustGetObject(source), objectMeta.GetNamespace()); err == nil && name == objectMeta.GetName() {
+			sources = append(sources, sourceKey)
 		}
 	}
+
+	return sources
 }
 
-func (r *GenericReplicator) DeleteResource(namespace v1.Namespace, source interface{}) {
-	sourceKey := MustGetKey(source)
+func (r *GenericReplicator) ResourceDeletedReplicateTo(source interface{}) error {
+	objMeta := MustGetObject(source)
+	selector, err := NewNamespaceSelector(objMeta.GetAnnotations(), ReplicateTo, ReplicateToMatching)
+	if err != nil {
+		return errors.Wrapf(err, "Could not delete replicas of %s: %v", MustGetKey(source), err)
+	}
+	if selector == nil {
+		return nil
+	}
 
-	logger := log.WithField("kind", r.Kind).WithField("source", sourceKey)
+	return r.DeleteResources(source, r.getNamespacesToReplicate(objMeta.GetNamespace(), selector, namespaceWatcher.Namespaces()))
+}
+
+// DeleteResources deletes the replicas of source in the given namespaces
+func (r *GenericReplicator) DeleteResources(source interface{}, namespaces []v1.Namespace) error {
+	var result error
+
+	for _, namespace := range namespaces {
+		if err := r.DeleteResource(namespace, source); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	return result
+}
+
+// DeleteResource deletes the replica of source in namespace if the replicator has created it, and only releases it
+// otherwise
+func (r *GenericReplicator) DeleteResource(namespace v1.Namespace, source interface{}) error {
 	objMeta := MustGetObject(source)
 
 	if namespace.Name == objMeta.GetNamespace() {
 		// Don't work upon itself
-		return
+		return nil
 	}
-	targetLocation := fmt.Sprintf("%s/%s", namespace.Name, objMeta.GetName())
-	targetResource, exists, err := r.Store.GetByKey(targetLocation)
+	targetName, err := TargetName(objMeta, namespace.Name)
 	if err != nil {
-		logger.WithError(err).Errorf("Could not get objectMeta %s: %+v", targetLocation, err)
-		return
+		return errors.Wrapf(err, "Could not delete replica of %s in namespace %s: %v", MustGetKey(source), namespace.Name, err)
+	}
+
+	targetLocation := fmt.Sprintf("%s/%s", namespace.Name, targetName)
+	targetResource, exists, err := r.TargetStore.GetByKey(targetLocation)
+	if err != nil {
+		return errors.Wrapf(err, "Could not get objectMeta %s: %v", targetLocation, err)
 	}
 	if !exists {
-		return
+		return nil
+	}
+	if !r.IsReplicaOf(MustGetObject(targetResource), objMeta) {
+		log.WithField("kind", r.Kind).WithField("source", MustGetKey(source)).WithField("target", targetLocation).
+			Infof("not deleting %s %s since it is not labeled as replica of %s", r.Kind, targetLocation, MustGetKey(source))
+		return nil
+	}
+	if !isCreatedReplica(MustGetObject(targetResource)) {
+		if err := r.UpdateFuncs.ReleaseReplicatedResource(targetResource); err != nil {
+			return errors.Wrapf(err, "Could not release resource %s: %v", targetLocation, err)
+		}
+		return nil
 	}
 	if err := r.UpdateFuncs.DeleteReplicatedResource(targetResource); err != nil {
-		logger.WithError(err).Errorf("Could not delete resource %s: %+v", targetLocation, err)
+		return errors.Wrapf(err, "Could not delete resource %s: %v", targetLocation, err)
 	}
+
+	return nil
 }
 
-func (r *GenericReplicator) ResourceDeletedReplicateFrom(source interface{}) {
-	sourceKey := MustGetKey(source)
+func (r *GenericReplicator) ResourceDeletedReplicateFrom(source interface{}) error {
+	return r.syncDependents(MustGetKey(source))
+}
 
+// syncDependents replicates into all dependents of the source with the given key from those of their sources that
+// still exist. Dependents without any source left are cleared by UpdateFuncs.PatchDeleteDependent.
+func (r *GenericReplicator) syncDependents(sourceKey string) error {
 	logger := log.WithField("kind", r.Kind).WithField("source", sourceKey)
-	replicas, ok := r.DependencyMap[sourceKey]
-	if !ok {
-		logger.Debugf("%s %s has no dependents and can be deleted without issues", r.Kind, sourceKey)
-		return
+	replicas := r.Dependencies.Dependents(sourceKey)
+	if len(replicas) == 0 {
+		logger.Debugf("%s has no dependents", sourceKey)
+		return nil
 	}
 
-	for dependentKey := range replicas {
+	var result error
+
+	for _, dependentKey := range replicas {
 		target, err := r.ObjectFromStore(dependentKey)
 		if err != nil {
 			logger.WithError(err).Warnf("could not load dependent %s %s: %v", r.Kind, dependentKey, err)
 			continue
 		}
+
+		// dependents with other sources left keep the data of those
+		remaining, _, err := r.existingSources(dependentKey)
+		if err != nil {
+			result = multierror.Append(result, errors.Wrapf(err, "could not update dependent %s %s: %v", r.Kind, dependentKey, err))
+			continue
+		} else if len(remaining) > 0 {
+			if err := r.replicateDataFrom(remaining, target); err != nil {
+				result = multierror.Append(result, errors.Wrapf(err, "could not update dependent %s %s: %v", r.Kind, dependentKey, err))
+			}
+			continue
+		}
+
 		s, err := r.UpdateFuncs.PatchDeleteDependent(sourceKey, target)
 		if err != nil {
-			logger.WithError(err).Warnf("could not patch dependent %s %s: %v", r.Kind, dependentKey, err)
+			result = multierror.Append(result, errors.Wrapf(err, "could not patch dependent %s %s: %v", r.Kind, dependentKey, err))
 			continue
 		}
 		if err := r.Store.Update(s); err != nil {
 			logger.WithError(err).Errorf("Error updating store for %s %s: %v", r.Kind, MustGetKey(s), err)
 		}
+		r.setReplicationStatus(s, ReplicationStatus{
+			Status:  StatusSourceMissing,
+			Message: fmt.Sprintf("Source %s %s has been deleted", r.Kind, sourceKey),
+		})
 	}
+
+	return result
 }
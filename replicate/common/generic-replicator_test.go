@@ -0,0 +1,674 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// recordingReplicator collects the replications requested by a GenericReplicator
+type recordingReplicator struct {
+	lock     sync.Mutex
+	pushed   map[string]struct{}
+	pulled   map[string]struct{}
+	deleted  map[string]struct{}
+	released map[string]struct{}
+}
+
+func newRecordingReplicator() *recordingReplicator {
+	return &recordingReplicator{
+		pushed:   make(map[string]struct{}),
+		pulled:   make(map[string]struct{}),
+		deleted:  make(map[string]struct{}),
+		released: make(map[string]struct{}),
+	}
+}
+
+func (r *recordingReplicator) updateFuncs() UpdateFuncs {
+	return UpdateFuncs{
+		ReplicateDataFrom: func(source interface{}, target interface{}) error {
+			r.lock.Lock()
+			defer r.lock.Unlock()
+			r.pulled[MustGetKey(target)] = struct{}{}
+			return nil
+		},
+		ReplicateObjectTo: func(source interface{}, target *v1.Namespace) error {
+			r.lock.Lock()
+			defer r.lock.Unlock()
+			r.pushed[target.Name] = struct{}{}
+			return nil
+		},
+		PatchDeleteDependent: func(sourceKey string, target interface{}) (interface{}, error) {
+			return target, nil
+		},
+		DeleteReplicatedResource: func(target interface{}) error {
+			r.lock.Lock()
+			defer r.lock.Unlock()
+			r.deleted[MustGetKey(target)] = struct{}{}
+			return nil
+		},
+		ReleaseReplicatedResource: func(target interface{}) error {
+			r.lock.Lock()
+			defer r.lock.Unlock()
+			r.released[MustGetKey(target)] = struct{}{}
+			return nil
+		},
+	}
+}
+
+func (r *recordingReplicator) pushedTo(namespace string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_, ok := r.pushed[namespace]
+	return ok
+}
+
+func (r *recordingReplicator) pulledInto(key string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_, ok := r.pulled[key]
+	return ok
+}
+
+func (r *recordingReplicator) deletedFrom(key string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_, ok := r.deleted[key]
+	return ok
+}
+
+func (r *recordingReplicator) releasedFrom(key string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_, ok := r.released[key]
+	return ok
+}
+
+func newTestReplicator(client kubernetes.Interface) (*GenericReplicator, *recordingReplicator) {
+	repl := NewGenericReplicator(ReplicatorConfig{
+		Kind:         "ConfigMap",
+		ObjType:      &v1.ConfigMap{},
+		Client:       client,
+		ResyncPeriod: time.Minute,
+		AllowAll:     true,
+		MaxRetries:   5,
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().ConfigMaps("").List(lo)
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return client.CoreV1().ConfigMaps("").Watch(lo)
+		},
+		PatchFunc: func(namespace string, name string, pt types.PatchType, data []byte) (runtime.Object, error) {
+			return client.CoreV1().ConfigMaps(namespace).Patch(name, pt, data)
+		},
+	})
+
+	recorder := newRecordingReplicator()
+	repl.UpdateFuncs = recorder.updateFuncs()
+
+	return repl, recorder
+}
+
+func waitFor(t *testing.T, timeout time.Duration, condition func() bool) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.True(t, condition(), "condition not met within %s", timeout)
+}
+
+func TestGenericReplicatorConcurrentNamespacesAndUpdates(t *testing.T) {
+	const namespaceCount = 40
+	// the watchers of the fake clientset panic once more than 100 events are pending, so the namespaces are created in
+	// waves, with all writes of a wave happening concurrently
+	const waveSize = 10
+
+	client := fake.NewSimpleClientset(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "source"}})
+	repl, recorder := newTestReplicator(client)
+	go repl.Run()
+
+	// the fake clientset drops events that happen between listing and watching
+	stop := make(chan struct{})
+	defer close(stop)
+	require.True(t, cache.WaitForCacheSync(stop, repl.Synced, namespaceWatcher.NamespaceController.HasSynced))
+
+	source := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "push",
+			Namespace: "source",
+			Annotations: map[string]string{
+				ReplicateTo: "hammer-.*",
+			},
+		},
+	}
+	_, err := client.CoreV1().ConfigMaps("source").Create(source)
+	require.NoError(t, err)
+
+	replicatedUpTo := func(count int) func() bool {
+		return func() bool {
+			for i := 0; i < count; i++ {
+				ns := fmt.Sprintf("hammer-%d", i)
+				if !recorder.pushedTo(ns) || !recorder.pulledInto(ns+"/pull") {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	for wave := 0; wave < namespaceCount; wave += waveSize {
+		wg := sync.WaitGroup{}
+
+		for i := wave; i < wave+waveSize; i++ {
+			wg.Add(3)
+			go func(i int) {
+				defer wg.Done()
+				ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("hammer-%d", i)}}
+				_, err := client.CoreV1().Namespaces().Create(ns)
+				assert.NoError(t, err)
+			}(i)
+			go func(i int) {
+				defer wg.Done()
+				update := source.DeepCopy()
+				update.Data = map[string]string{"revision": fmt.Sprint(i)}
+				_, err := client.CoreV1().ConfigMaps("source").Update(update)
+				assert.NoError(t, err)
+			}(i)
+			go func(i int) {
+				defer wg.Done()
+				target := &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "pull",
+						Namespace: fmt.Sprintf("hammer-%d", i),
+						Annotations: map[string]string{
+							ReplicateFromAnnotation: "source/push",
+						},
+					},
+				}
+				_, err := client.CoreV1().ConfigMaps(target.Namespace).Create(target)
+				assert.NoError(t, err)
+			}(i)
+		}
+
+		wg.Wait()
+		waitFor(t, 10*time.Second, replicatedUpTo(wave+waveSize))
+	}
+
+	require.Len(t, repl.Dependencies.Dependents("source/push"), namespaceCount)
+	require.True(t, repl.ReplicateToList.Has("source/push"))
+}
+
+func TestReplicationDeniedIsRecordedOnTarget(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl, _ := newTestReplicator(client)
+	repl.AllowAll = false
+
+	recorder := record.NewFakeRecorder(10)
+	repl.Recorder = recorder
+	repl.UpdateFuncs.ReplicateDataFrom = func(source interface{}, target interface{}) error {
+		sourceObject := source.(*v1.ConfigMap)
+		targetObject := target.(*v1.ConfigMap)
+		_, err := repl.IsReplicationPermitted(&targetObject.ObjectMeta, &sourceObject.ObjectMeta)
+		return err
+	}
+
+	source := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "a"}}
+	target := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "target", Namespace: "b"}}
+
+	err := repl.replicateDataFrom([]interface{}{source}, target)
+	require.Error(t, err)
+	require.True(t, IsReplicationDenied(err))
+
+	event := <-recorder.Events
+	require.Contains(t, event, ReasonReplicationDenied)
+}
+
+func TestMissingSourceIsReportedInTargetStatus(t *testing.T) {
+	target := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "target",
+			Namespace: "b",
+			Annotations: map[string]string{
+				ReplicateFromAnnotation: "a/missing",
+			},
+		},
+	}
+
+	client := fake.NewSimpleClientset(target)
+	repl, _ := newTestReplicator(client)
+	require.NoError(t, repl.Store.Add(target))
+
+	require.Error(t, repl.ResourceAdded(target))
+
+	updated, err := client.CoreV1().ConfigMaps("b").Get("target", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	status := ReplicationStatus{}
+	require.NoError(t, json.Unmarshal([]byte(updated.Annotations[ReplicationStatusAnnotation]), &status))
+	require.Equal(t, StatusSourceMissing, status.Status)
+}
+
+func TestPushStatusListsFailedNamespaces(t *testing.T) {
+	targets := []v1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "a"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c"}},
+	}
+
+	status := pushStatusFor(targets, targets[:2])
+
+	require.Equal(t, []string{"a", "b"}, status.ReplicatedTo)
+	require.Equal(t, []string{"c"}, status.Failed)
+}
+
+func TestNamespaceRelabelingQueuesPushSourcesAndTargets(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl, _ := newTestReplicator(client)
+
+	repl.ReplicateToList.Add("source/push")
+	repl.Dependencies.Add("source/pull", "tenant/pull")
+	repl.Dependencies.Add("source/pull", "other/pull")
+
+	old := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}}
+	relabeled := old.DeepCopy()
+
+	repl.NamespaceUpdated(old, relabeled)
+	require.Equal(t, 0, repl.Queue.Len())
+
+	relabeled.Labels = map[string]string{"team": "payments"}
+	repl.NamespaceUpdated(old, relabeled)
+	require.Equal(t, 2, repl.Queue.Len())
+}
+
+func TestNamespaceNoLongerMatchingLosesReplica(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl, recorder := newTestReplicator(client)
+
+	source := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "push",
+			Namespace: "source",
+			Annotations: map[string]string{
+				ReplicateToMatching: "team=payments",
+			},
+		},
+	}
+	replica := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "push",
+			Namespace:   "tenant",
+			Labels:      map[string]string{ReplicatedFromLabel: "source.push", ReplicatedFromKindLabel: "ConfigMap"},
+			Annotations: map[string]string{CreatedReplicaAnnotation: "true"},
+		},
+	}
+	existing := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "push",
+			Namespace: "shared",
+			Labels:    map[string]string{ReplicatedFromLabel: "source.push", ReplicatedFromKindLabel: "ConfigMap"},
+		},
+	}
+	unrelated := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "push", Namespace: "other"}}
+	require.NoError(t, repl.Store.Add(source))
+	require.NoError(t, repl.Store.Add(replica))
+	require.NoError(t, repl.Store.Add(existing))
+	require.NoError(t, repl.Store.Add(unrelated))
+	repl.ReplicateToList.Add("source/push")
+
+	for _, namespace := range []string{"tenant", "shared", "other"} {
+		old := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: map[string]string{"team": "payments"}}}
+		relabeled := old.DeepCopy()
+		relabeled.Labels["team"] = "search"
+
+		repl.NamespaceUpdated(old, relabeled)
+	}
+
+	require.True(t, recorder.deletedFrom("tenant/push"))
+	require.False(t, recorder.releasedFrom("tenant/push"))
+	require.False(t, recorder.deletedFrom("shared/push"), "objects not created by the replicator must not be deleted")
+	require.True(t, recorder.releasedFrom("shared/push"))
+	require.False(t, recorder.deletedFrom("other/push"), "objects not labeled as replica must not be deleted")
+	require.False(t, recorder.releasedFrom("other/push"))
+}
+
+func TestNamespaceDeletionPurgesDependencies(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl, _ := newTestReplicator(client)
+
+	repl.Dependencies.Add("source/pull", "tenant/pull")
+	repl.Dependencies.Add("source/pull", "other/pull")
+	repl.Dependencies.Add("tenant/other", "other/other")
+	repl.ReplicateToList.Add("tenant/push")
+	repl.ReplicateToList.Add("source/push")
+
+	repl.NamespaceDeleted(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}})
+
+	require.Equal(t, []string{"other/pull"}, repl.Dependencies.Dependents("source/pull"))
+	require.Equal(t, []string{"other/other"}, repl.Dependencies.Dependents("tenant/other"))
+	require.Equal(t, []string{"source/push"}, repl.ReplicateToList.List())
+	require.Equal(t, 1, repl.Queue.Len())
+}
+
+func TestDeletedReplicaQueuesItsSource(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl, _ := newTestReplicator(client)
+
+	for _, name := range []string{"settings", "defaults"} {
+		require.NoError(t, repl.Store.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "platform"}}))
+		repl.ReplicateToList.Add("platform/" + name)
+	}
+
+	replica := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "settings",
+			Namespace:   "tenant",
+			Annotations: map[string]string{ReplicatedFromVersionAnnotation: "1"},
+		},
+	}
+
+	require.NoError(t, repl.ResourceDeleted(replica))
+	require.Equal(t, 1, repl.Queue.Len())
+
+	key, _ := repl.Queue.Get()
+	require.Equal(t, "platform/settings", key)
+}
+
+func TestRenamedReplicasAreTrackedByTheirTargetName(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl, recorder := newTestReplicator(client)
+
+	source := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "registry-creds-v3",
+			Namespace: "platform",
+			Annotations: map[string]string{
+				ReplicateToMatching:       "team=payments",
+				ReplicateToNameAnnotation: "regcred",
+			},
+		},
+	}
+	replica := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "regcred",
+			Namespace: "tenant",
+			Labels: map[string]string{
+				ReplicatedFromLabel:     "platform.registry-creds-v3",
+				ReplicatedFromKindLabel: "ConfigMap",
+			},
+			Annotations: map[string]string{ReplicatedFromVersionAnnotation: "1", CreatedReplicaAnnotation: "true"},
+		},
+	}
+	require.NoError(t, repl.Store.Add(source))
+	require.NoError(t, repl.Store.Add(replica))
+	repl.ReplicateToList.Add("platform/registry-creds-v3")
+
+	require.Equal(t, []string{"platform/registry-creds-v3"}, repl.PushSourcesOf(replica))
+
+	old := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Labels: map[string]string{"team": "payments"}}}
+	relabeled := old.DeepCopy()
+	relabeled.Labels["team"] = "search"
+
+	repl.NamespaceUpdated(old, relabeled)
+	require.True(t, recorder.deletedFrom("tenant/regcred"))
+}
+
+func TestMultipleSourcesAreMergedInOrder(t *testing.T) {
+	base := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "base", Namespace: "a"}}
+	overlay := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "overlay", Namespace: "a"}}
+	target := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "target",
+			Namespace: "b",
+			Annotations: map[string]string{
+				ReplicateFromAnnotation: "a/base, a/overlay",
+			},
+		},
+	}
+
+	client := fake.NewSimpleClientset(base, overlay, target)
+	repl, recorder := newTestReplicator(client)
+	for _, obj := range []interface{}{base, overlay, target} {
+		require.NoError(t, repl.Store.Add(obj))
+	}
+
+	var merged []string
+	repl.UpdateFuncs.MergeDataFrom = func(sources []interface{}, target interface{}) error {
+		merged = keysOf(sources)
+		return nil
+	}
+
+	require.NoError(t, repl.ResourceAdded(target))
+	require.Equal(t, []string{"a/base", "a/overlay"}, merged)
+	require.Equal(t, []string{"b/target"}, repl.Dependencies.Dependents("a/overlay"))
+	require.False(t, recorder.pulledInto("b/target"))
+
+	// the remaining source is replicated again instead of clearing the target
+	require.NoError(t, repl.Store.Delete(overlay))
+	require.NoError(t, repl.ResourceDeleted(overlay))
+	require.True(t, recorder.pulledInto("b/target"))
+
+	repl.UpdateFuncs.MergeDataFrom = nil
+	require.NoError(t, repl.Store.Add(overlay))
+	require.Error(t, repl.ResourceAdded(target))
+}
+
+func TestSourcesOfAnotherKind(t *testing.T) {
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "a"}}
+	target := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "target",
+			Namespace: "b",
+			Annotations: map[string]string{
+				ReplicateFromAnnotation: "secret:a/ca",
+			},
+		},
+	}
+
+	client := fake.NewSimpleClientset(secret, target)
+	repl, recorder := newTestReplicator(client)
+	require.NoError(t, repl.Store.Add(target))
+
+	// replicators without a matching source kind reject the target
+	require.Error(t, repl.ResourceAdded(target))
+	require.False(t, recorder.pulledInto("b/target"))
+
+	repl = NewGenericReplicator(ReplicatorConfig{
+		Kind:         "ConfigMap",
+		ObjType:      &v1.ConfigMap{},
+		Client:       client,
+		ResyncPeriod: time.Minute,
+		AllowAll:     true,
+		ListFunc:     repl.ListFunc,
+		WatchFunc:    repl.WatchFunc,
+		PatchFunc:    repl.PatchFunc,
+		SourceKinds: map[string]SourceKind{
+			"secret": {
+				ObjType: &v1.Secret{},
+				ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+					return client.CoreV1().Secrets("").List(lo)
+				},
+				WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+					return client.CoreV1().Secrets("").Watch(lo)
+				},
+			},
+		},
+	})
+	repl.UpdateFuncs = recorder.updateFuncs()
+	require.NoError(t, repl.Store.Add(target))
+
+	// the informer for secrets is started on first use and the target is replicated once it has synced
+	require.Eventually(t, func() bool {
+		return repl.ResourceAdded(target) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.True(t, recorder.pulledInto("b/target"))
+	require.Equal(t, []string{"b/target"}, repl.Dependencies.Dependents("secret:a/ca"))
+}
+
+func TestOrphanedReplicasAreCollected(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl, recorder := newTestReplicator(client)
+
+	require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "gc-tenant", Labels: map[string]string{"team": "payments"}},
+	}))
+	require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "gc-other"},
+	}))
+
+	require.NoError(t, repl.Store.Add(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "creds",
+			Namespace: "gc-platform",
+			Annotations: map[string]string{
+				ReplicateToMatching:       "team=payments",
+				ReplicateToNameAnnotation: "regcred",
+			},
+		},
+	}))
+
+	replica := func(namespace string, name string, source string, kind string) *v1.ConfigMap {
+		labels := map[string]string{}
+		if source != "" {
+			labels[ReplicatedFromLabel] = SourceReference(source)
+			labels[ReplicatedFromKindLabel] = kind
+		}
+		annotations := map[string]string{CreatedReplicaAnnotation: "true"}
+		return &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		}}
+	}
+	merged := replica("gc-tenant", "merged", "gc-platform/deleted", "ConfigMap")
+	delete(merged.Annotations, CreatedReplicaAnnotation)
+
+	for _, obj := range []*v1.ConfigMap{
+		replica("gc-tenant", "regcred", "gc-platform/creds", "ConfigMap"),
+		replica("gc-tenant", "creds", "gc-platform/creds", "ConfigMap"),
+		replica("gc-other", "regcred", "gc-platform/creds", "ConfigMap"),
+		replica("gc-tenant", "deleted", "gc-platform/deleted", "ConfigMap"),
+		replica("gc-tenant", "manual", "", ""),
+		replica("gc-unknown", "deleted", "gc-platform/deleted", "ConfigMap"),
+		replica("gc-tenant", "other-kind", "gc-platform/deleted", "Secret"),
+		merged,
+	} {
+		require.NoError(t, repl.Store.Add(obj))
+	}
+
+	repl.CollectGarbage()
+
+	require.False(t, recorder.deletedFrom("gc-tenant/regcred"), "replica still targeted by its source")
+	require.True(t, recorder.deletedFrom("gc-tenant/creds"), "replica under a name its source does not use any more")
+	require.True(t, recorder.deletedFrom("gc-other/regcred"), "replica in a namespace not targeted any more")
+	require.True(t, recorder.deletedFrom("gc-tenant/deleted"), "replica of a deleted source")
+	require.False(t, recorder.deletedFrom("gc-tenant/manual"), "object not labeled as replica")
+	require.False(t, recorder.deletedFrom("gc-unknown/deleted"), "replica in an unknown namespace")
+	require.False(t, recorder.deletedFrom("gc-tenant/other-kind"), "replica of a source of another kind")
+	require.False(t, recorder.deletedFrom("gc-tenant/merged"), "existing object that has only been replicated into")
+}
+
+func TestColonsInNamesAreNoSourceKindPrefix(t *testing.T) {
+	prefix, key, ok := sourceKindPrefix("secret:a/system:ca")
+	require.True(t, ok)
+	require.Equal(t, "secret", prefix)
+	require.Equal(t, "a/system:ca", key)
+
+	_, key, ok = sourceKindPrefix("a/system:controller:foo")
+	require.False(t, ok)
+	require.Equal(t, "a/system:controller:foo", key)
+
+	client := fake.NewSimpleClientset()
+	recorder := newRecordingReplicator()
+	repl := NewGenericReplicator(ReplicatorConfig{
+		Kind:         "Role",
+		ObjType:      &rbacv1.Role{},
+		Client:       client,
+		ResyncPeriod: time.Minute,
+		AllowAll:     true,
+		MaxRetries:   5,
+		ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+			return client.RbacV1().Roles("").List(lo)
+		},
+		WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+			return client.RbacV1().Roles("").Watch(lo)
+		},
+	})
+	repl.UpdateFuncs = recorder.updateFuncs()
+
+	require.NoError(t, namespaceWatcher.NamespaceStore.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "colon-tenant"}}))
+
+	source := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "system:controller:deployer",
+			Namespace:   "colon-platform",
+			Annotations: map[string]string{ReplicateTo: "colon-tenant"},
+		},
+	}
+	replica := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: source.Name, Namespace: "colon-tenant"}}
+	repl.SetSourceReference(source, replica, true)
+	require.NoError(t, repl.Store.Add(replica))
+
+	// the deleted source is processed as deletion of a role, not as a source of another kind named "colon-platform/system"
+	repl.enqueueDeleted(source)
+	require.NoError(t, repl.syncKey(MustGetKey(source)))
+
+	require.True(t, recorder.deletedFrom("colon-tenant/system:controller:deployer"))
+	_, pending := repl.deletedObject(MustGetKey(source))
+	require.False(t, pending, "processed deletions must be forgotten")
+}
+
+func TestFailedSyncsAreRetriedUntilMaxRetries(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl, _ := newTestReplicator(client)
+	repl.MaxRetries = 2
+	repl.Queue = workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, time.Millisecond))
+
+	attempts := 0
+	repl.UpdateFuncs.ReplicateDataFrom = func(source interface{}, target interface{}) error {
+		attempts++
+		return fmt.Errorf("attempt %d failed", attempts)
+	}
+
+	require.NoError(t, repl.Store.Add(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "retry"}}))
+	require.NoError(t, repl.Store.Add(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "target",
+			Namespace:   "retry",
+			Annotations: map[string]string{ReplicateFromAnnotation: "retry/source"},
+		},
+	}))
+	repl.Queue.Add("retry/target")
+
+	for retries := 1; retries <= repl.MaxRetries; retries++ {
+		require.True(t, repl.processNextItem())
+		require.Equal(t, retries, attempts)
+		require.Equal(t, retries, repl.Queue.NumRequeues("retry/target"), "failed sync is requeued with backoff")
+	}
+
+	require.True(t, repl.processNextItem())
+	require.Equal(t, repl.MaxRetries+1, attempts)
+	require.Equal(t, 0, repl.Queue.NumRequeues("retry/target"), "key is forgotten once MaxRetries is exceeded")
+
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 0, repl.Queue.Len(), "key is dropped once MaxRetries is exceeded")
+}
@@ -0,0 +1,280 @@
+package common
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KeyFilter decides which keys of a secret or config map are replicated, based on the glob pattern lists in the
+// ReplicateKeysAnnotation and ExcludeKeysAnnotation of the involved objects. A key is replicated if it matches at
+// least one ReplicateKeysAnnotation pattern of every object having that annotation, and no ExcludeKeysAnnotation
+// pattern of any object.
+type KeyFilter struct {
+	include [][]string
+	exclude []string
+}
+
+// NewKeyFilter builds a key filter from the annotations of objects, typically the source and the target of a
+// replication
+func NewKeyFilter(objects ...*metav1.ObjectMeta) (*KeyFilter, error) {
+	filter := KeyFilter{}
+
+	for _, object := range objects {
+		if object == nil {
+			continue
+		}
+
+		if list, ok := object.Annotations[ReplicateKeysAnnotation]; ok {
+			patterns, err := stringToGlobList(list)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s annotation on %s", ReplicateKeysAnnotation, MustGetKey(object))
+			}
+			filter.include = append(filter.include, patterns)
+		}
+
+		if list, ok := object.Annotations[ExcludeKeysAnnotation]; ok {
+			patterns, err := stringToGlobList(list)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s annotation on %s", ExcludeKeysAnnotation, MustGetKey(object))
+			}
+			filter.exclude = append(filter.exclude, patterns...)
+		}
+	}
+
+	return &filter, nil
+}
+
+// NewConfigMapAllowedKeysFilter builds a key filter from the ConfigMapAllowedKeysAnnotation of secret, selecting the
+// keys that may be replicated into config maps. Secrets without that annotation may not be replicated into config
+// maps at all, regardless of the replicator's -allow-all flag.
+func NewConfigMapAllowedKeysFilter(secret *metav1.ObjectMeta) (*KeyFilter, error) {
+	list, ok := secret.Annotations[ConfigMapAllowedKeysAnnotation]
+	if !ok {
+		return nil, newReplicationDeniedError("secret %s does not allow replication into config maps (%s annotation missing)",
+			MustGetKey(secret), ConfigMapAllowedKeysAnnotation)
+	}
+
+	patterns, err := stringToGlobList(list)
+	if err != nil {
+		return nil, newReplicationDeniedError("secret %s does not allow replication into config maps: invalid %s annotation: %v",
+			MustGetKey(secret), ConfigMapAllowedKeysAnnotation, err)
+	}
+
+	return &KeyFilter{include: [][]string{patterns}}, nil
+}
+
+// Matches checks whether key should be replicated
+func (f *KeyFilter) Matches(key string) bool {
+	for _, patterns := range f.include {
+		if !matchesAny(patterns, key) {
+			return false
+		}
+	}
+
+	return !matchesAny(f.exclude, key)
+}
+
+// Keys returns the sorted list of those keys that should be replicated
+func (f *KeyFilter) Keys(keys ...[]string) []string {
+	result := make([]string, 0)
+	for _, list := range keys {
+		for _, key := range list {
+			if f.Matches(key) {
+				result = append(result, key)
+			}
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func matchesAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		// patterns have been validated in stringToGlobList
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stringToGlobList(list string) ([]string, error) {
+	result := make([]string, 0)
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern '%s'", s)
+		}
+		result = append(result, s)
+	}
+
+	return result, nil
+}
+
+// KeyMapping renames replicated keys according to the ReplicateKeyMapAnnotation and ReplicateKeyPrefixAnnotation of
+// a target. Keys listed in the key map are renamed as specified, all other keys are prefixed.
+type KeyMapping struct {
+	names  map[string]string
+	prefix string
+}
+
+// NewKeyMapping builds a key mapping from the annotations of target
+func NewKeyMapping(target *metav1.ObjectMeta) (*KeyMapping, error) {
+	mapping := KeyMapping{
+		names:  make(map[string]string),
+		prefix: target.Annotations[ReplicateKeyPrefixAnnotation],
+	}
+
+	list, ok := target.Annotations[ReplicateKeyMapAnnotation]
+	if !ok {
+		return &mapping, nil
+	}
+
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, errors.Errorf("invalid %s annotation on %s: expected <key>=<new key>, got '%s'",
+				ReplicateKeyMapAnnotation, MustGetKey(target), s)
+		}
+
+		mapping.names[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return &mapping, nil
+}
+
+// Name returns the name under which key is stored in the target
+func (m *KeyMapping) Name(key string) string {
+	if name, ok := m.names[key]; ok {
+		return name
+	}
+
+	return m.prefix + key
+}
+
+// ReplicatedKeyNames maps every key of keys selected by filter to the name under which it is stored in the target.
+// mapping may be nil, in which case keys are not renamed. An error is returned if two keys would end up with the same
+// name.
+func ReplicatedKeyNames(filter *KeyFilter, mapping *KeyMapping, keys ...[]string) (map[string]string, error) {
+	names := make(map[string]string)
+	sources := make(map[string]string)
+
+	for _, key := range filter.Keys(keys...) {
+		name := key
+		if mapping != nil {
+			name = mapping.Name(key)
+		}
+
+		if other, ok := sources[name]; ok {
+			return nil, errors.Errorf("keys %s and %s would both be replicated as %s", other, key, name)
+		}
+
+		names[key] = name
+		sources[name] = key
+	}
+
+	return names, nil
+}
+
+// ReplicatedKeysUpToDate checks whether the keys recorded in the ReplicatedKeysAnnotation of target are exactly the
+// target names in names
+func ReplicatedKeysUpToDate(target *metav1.ObjectMeta, names map[string]string) bool {
+	expected := make([]string, 0, len(names))
+	for _, name := range names {
+		expected = append(expected, name)
+	}
+	sort.Strings(expected)
+
+	return target.Annotations[ReplicatedKeysAnnotation] == strings.Join(expected, ",")
+}
+
+// KeyProvenance records from which source each key of a target originates. Sources are added in order of increasing
+// precedence, so that a key provided by several sources is attributed to the last of them.
+type KeyProvenance struct {
+	sources []string
+	origins map[string]string
+}
+
+// NewKeyProvenance creates an empty key provenance
+func NewKeyProvenance() *KeyProvenance {
+	return &KeyProvenance{origins: make(map[string]string)}
+}
+
+// Add records that source provides the keys in names, which maps source keys to their names in the target
+func (p *KeyProvenance) Add(source string, names map[string]string) {
+	p.sources = append(p.sources, source)
+	for _, name := range names {
+		p.origins[name] = source
+	}
+}
+
+// AddDerived records that the keys in names are derived from the sources' data rather than copied, e.g. rendered
+// from a template. Derived keys take precedence over keys provided by sources.
+func (p *KeyProvenance) AddDerived(origin string, names ...string) {
+	for _, name := range names {
+		p.origins[name] = origin
+	}
+}
+
+// Keys returns the sorted names of all keys in the target
+func (p *KeyProvenance) Keys() []string {
+	keys := make([]string, 0, len(p.origins))
+	for name := range p.origins {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// String returns the provenance in the format <key>=<source>,..., sorted by key
+func (p *KeyProvenance) String() string {
+	entries := make([]string, 0, len(p.origins))
+	for _, name := range p.Keys() {
+		entries = append(entries, name+"="+p.origins[name])
+	}
+
+	return strings.Join(entries, ",")
+}
+
+// Apply stores the provenance in the ReplicatedKeysAnnotation and, for targets with more than one source, the
+// ReplicatedKeySourcesAnnotation
+func (p *KeyProvenance) Apply(annotations map[string]string) {
+	annotations[ReplicatedKeysAnnotation] = strings.Join(p.Keys(), ",")
+
+	if len(p.sources) > 1 {
+		annotations[ReplicatedKeySourcesAnnotation] = p.String()
+	} else {
+		delete(annotations, ReplicatedKeySourcesAnnotation)
+	}
+}
+
+// UpToDate checks whether the annotations of target match the provenance
+func (p *KeyProvenance) UpToDate(target *metav1.ObjectMeta) bool {
+	expected := make(map[string]string)
+	p.Apply(expected)
+
+	for _, annotation := range []string{ReplicatedKeysAnnotation, ReplicatedKeySourcesAnnotation} {
+		value, ok := target.Annotations[annotation]
+		expectedValue, expectedOk := expected[annotation]
+		if ok != expectedOk || value != expectedValue {
+			return false
+		}
+	}
+
+	return true
+}
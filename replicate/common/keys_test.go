@@ -0,0 +1,114 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func meta(annotations map[string]string) *metav1.ObjectMeta {
+	return &metav1.ObjectMeta{Name: "obj", Namespace: "default", Annotations: annotations}
+}
+
+func TestKeyFilterWithoutAnnotationsMatchesEverything(t *testing.T) {
+	filter, err := NewKeyFilter(meta(nil), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"ca.crt", "tls.crt", "tls.key"}, filter.Keys([]string{"tls.key", "tls.crt", "ca.crt"}))
+}
+
+func TestKeyFilterCombinesSourceAndTarget(t *testing.T) {
+	filter, err := NewKeyFilter(
+		meta(map[string]string{ReplicateKeysAnnotation: "*.crt, *.pem"}),
+		meta(map[string]string{ExcludeKeysAnnotation: "tls.*"}),
+	)
+	require.NoError(t, err)
+
+	assert.True(t, filter.Matches("ca.crt"))
+	assert.True(t, filter.Matches("bundle.pem"))
+	assert.False(t, filter.Matches("tls.crt"))
+	assert.False(t, filter.Matches("tls.key"))
+	assert.False(t, filter.Matches("config.yaml"))
+}
+
+func TestKeyFilterRequiresAllIncludeLists(t *testing.T) {
+	filter, err := NewKeyFilter(
+		meta(map[string]string{ReplicateKeysAnnotation: "*.crt"}),
+		meta(map[string]string{ReplicateKeysAnnotation: "ca.*"}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"ca.crt"}, filter.Keys([]string{"ca.crt", "tls.crt", "ca.key"}))
+}
+
+func TestKeyFilterRejectsInvalidPatterns(t *testing.T) {
+	_, err := NewKeyFilter(meta(map[string]string{ExcludeKeysAnnotation: "tls.[key"}))
+
+	require.Error(t, err)
+}
+
+func TestKeyMappingRenamesAndPrefixes(t *testing.T) {
+	mapping, err := NewKeyMapping(meta(map[string]string{
+		ReplicateKeyMapAnnotation:    "password=POSTGRES_PASSWORD, user=POSTGRES_USER",
+		ReplicateKeyPrefixAnnotation: "DB_",
+	}))
+	require.NoError(t, err)
+
+	filter, err := NewKeyFilter()
+	require.NoError(t, err)
+
+	names, err := ReplicatedKeyNames(filter, mapping, []string{"password", "user", "host"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"password": "POSTGRES_PASSWORD",
+		"user":     "POSTGRES_USER",
+		"host":     "DB_host",
+	}, names)
+
+	assert.True(t, ReplicatedKeysUpToDate(meta(map[string]string{
+		ReplicatedKeysAnnotation: "DB_host,POSTGRES_PASSWORD,POSTGRES_USER",
+	}), names))
+	assert.False(t, ReplicatedKeysUpToDate(meta(map[string]string{
+		ReplicatedKeysAnnotation: "host,password,user",
+	}), names))
+}
+
+func TestKeyMappingRejectsCollisions(t *testing.T) {
+	mapping, err := NewKeyMapping(meta(map[string]string{ReplicateKeyMapAnnotation: "password=user"}))
+	require.NoError(t, err)
+
+	filter, err := NewKeyFilter()
+	require.NoError(t, err)
+
+	_, err = ReplicatedKeyNames(filter, mapping, []string{"password", "user"})
+	require.Error(t, err)
+}
+
+func TestKeyMappingRejectsInvalidEntries(t *testing.T) {
+	_, err := NewKeyMapping(meta(map[string]string{ReplicateKeyMapAnnotation: "password"}))
+
+	require.Error(t, err)
+}
+
+func TestKeyProvenancePrefersLaterSources(t *testing.T) {
+	provenance := NewKeyProvenance()
+	provenance.Add("ns/base", map[string]string{"a": "a", "b": "b"})
+	provenance.Add("ns/overlay", map[string]string{"b": "b", "c": "c"})
+
+	annotations := make(map[string]string)
+	provenance.Apply(annotations)
+
+	assert.Equal(t, "a,b,c", annotations[ReplicatedKeysAnnotation])
+	assert.Equal(t, "a=ns/base,b=ns/overlay,c=ns/overlay", annotations[ReplicatedKeySourcesAnnotation])
+	assert.True(t, provenance.UpToDate(meta(annotations)))
+
+	single := NewKeyProvenance()
+	single.Add("ns/base", map[string]string{"a": "a"})
+	single.Apply(annotations)
+
+	_, ok := annotations[ReplicatedKeySourcesAnnotation]
+	assert.False(t, ok)
+	assert.False(t, provenance.UpToDate(meta(annotations)))
+}
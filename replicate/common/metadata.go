@@ -0,0 +1,110 @@
+package common
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// MetadataPropagation selects the labels and annotations of a source that are copied into its push replicas. Labels
+// and annotations are selected if their key matches any of the configured patterns, or any of the patterns listed
+// in the source's PropagateLabelsAnnotation and PropagateAnnotationsAnnotation.
+type MetadataPropagation struct {
+	labels      []*regexp.Regexp
+	annotations []*regexp.Regexp
+}
+
+// NewMetadataPropagation creates a propagation from comma separated lists of regular expressions
+func NewMetadataPropagation(labels string, annotations string) MetadataPropagation {
+	return MetadataPropagation{
+		labels:      metadataPatterns(labels),
+		annotations: metadataPatterns(annotations),
+	}
+}
+
+// metadataPatterns parses a comma separated list of regular expressions, ignoring empty entries
+func metadataPatterns(list string) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, 0)
+	for _, pattern := range StringToPatternList(list) {
+		if pattern.String() != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+// Apply copies the selected labels and annotations of source into target, and removes those that were propagated
+// before but are not selected any more. The propagated keys are recorded in the ReplicatedLabelsAnnotation and
+// ReplicatedAnnotationsAnnotation of target.
+func (p MetadataPropagation) Apply(source *metav1.ObjectMeta, target *metav1.ObjectMeta) {
+	if target.Annotations == nil {
+		target.Annotations = make(map[string]string)
+	}
+
+	labelPatterns := append(metadataPatterns(source.Annotations[PropagateLabelsAnnotation]), p.labels...)
+	annotationPatterns := append(metadataPatterns(source.Annotations[PropagateAnnotationsAnnotation]), p.annotations...)
+
+	replicatedLabels := propagate(source.Labels, &target.Labels, labelPatterns, target.Annotations[ReplicatedLabelsAnnotation])
+	replicatedAnnotations := propagate(source.Annotations, &target.Annotations, annotationPatterns, target.Annotations[ReplicatedAnnotationsAnnotation])
+
+	setOrDelete(target.Annotations, ReplicatedLabelsAnnotation, strings.Join(replicatedLabels, ","))
+	setOrDelete(target.Annotations, ReplicatedAnnotationsAnnotation, strings.Join(replicatedAnnotations, ","))
+}
+
+// UpToDate checks whether the labels and annotations of target already match those selected from source
+func (p MetadataPropagation) UpToDate(source *metav1.ObjectMeta, target *metav1.ObjectMeta) bool {
+	expected := target.DeepCopy()
+	p.Apply(source, expected)
+
+	return labels.Equals(expected.Labels, target.Labels) && labels.Equals(expected.Annotations, target.Annotations)
+}
+
+// propagate copies the entries of from whose key matches any of patterns into *to and removes the previously
+// propagated entries (given as comma separated list) that are not copied any more. Annotations controlling the
+// replicator are never copied. It returns the sorted keys of the copied entries.
+func propagate(from map[string]string, to *map[string]string, patterns []*regexp.Regexp, previous string) []string {
+	propagated := make([]string, 0)
+	if *to == nil {
+		*to = make(map[string]string)
+	}
+
+	for key, value := range from {
+		if strings.HasPrefix(key, AnnotationPrefix) || !matchesAnyPattern(patterns, key) {
+			continue
+		}
+
+		(*to)[key] = value
+		propagated = append(propagated, key)
+	}
+	sort.Strings(propagated)
+
+	for _, key := range strings.Split(previous, ",") {
+		if i := sort.SearchStrings(propagated, key); key != "" && (i == len(propagated) || propagated[i] != key) {
+			delete(*to, key)
+		}
+	}
+
+	return propagated
+}
+
+func matchesAnyPattern(patterns []*regexp.Regexp, key string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setOrDelete(annotations map[string]string, key string, value string) {
+	if value == "" {
+		delete(annotations, key)
+	} else {
+		annotations[key] = value
+	}
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMetadataPropagation(t *testing.T) {
+	source := &metav1.ObjectMeta{
+		Labels: map[string]string{
+			"app.kubernetes.io/part-of": "billing",
+			"team":                      "payments",
+		},
+		Annotations: map[string]string{
+			ReplicateTo:                    "tenant-.*",
+			PropagateAnnotationsAnnotation: "^backup\\.",
+			"backup.example.com/schedule":  "daily",
+			"description":                  "not propagated",
+		},
+	}
+	target := &metav1.ObjectMeta{
+		Labels:      map[string]string{"local": "kept"},
+		Annotations: map[string]string{ReplicatedFromVersionAnnotation: "1"},
+	}
+
+	propagation := NewMetadataPropagation("^app\\.kubernetes\\.io/", "")
+	require.False(t, propagation.UpToDate(source, target))
+
+	propagation.Apply(source, target)
+	require.Equal(t, map[string]string{"app.kubernetes.io/part-of": "billing", "local": "kept"}, target.Labels)
+	require.Equal(t, "daily", target.Annotations["backup.example.com/schedule"])
+	require.NotContains(t, target.Annotations, "description")
+	require.NotContains(t, target.Annotations, ReplicateTo)
+	require.Equal(t, "app.kubernetes.io/part-of", target.Annotations[ReplicatedLabelsAnnotation])
+	require.Equal(t, "backup.example.com/schedule", target.Annotations[ReplicatedAnnotationsAnnotation])
+	require.True(t, propagation.UpToDate(source, target))
+
+	// labels and annotations that are not selected any more are removed, others are left alone
+	delete(source.Labels, "app.kubernetes.io/part-of")
+	delete(source.Annotations, PropagateAnnotationsAnnotation)
+	require.False(t, propagation.UpToDate(source, target))
+
+	propagation.Apply(source, target)
+	require.Equal(t, map[string]string{"local": "kept"}, target.Labels)
+	require.Equal(t, map[string]string{ReplicatedFromVersionAnnotation: "1"}, target.Annotations)
+}
+
+func TestEmptyMetadataPropagationSelectsNothing(t *testing.T) {
+	source := &metav1.ObjectMeta{Labels: map[string]string{"team": "payments"}}
+	target := &metav1.ObjectMeta{}
+
+	NewMetadataPropagation("", " , ").Apply(source, target)
+	require.Empty(t, target.Labels)
+	require.Empty(t, target.Annotations)
+}
@@ -0,0 +1,183 @@
+package common
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/metrics"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Replication modes used as metric labels
+const (
+	ModePush = "push"
+	ModePull = "pull"
+)
+
+const metricsNamespace = "replicator"
+
+var (
+	replicationAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "replication_attempts_total",
+		Help:      "Number of attempted replications",
+	}, []string{"kind", "mode"})
+
+	replicationSuccesses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "replication_successes_total",
+		Help:      "Number of successful replications",
+	}, []string{"kind", "mode"})
+
+	replicationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "replication_failures_total",
+		Help:      "Number of failed replications",
+	}, []string{"kind", "mode"})
+
+	apiWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_write_duration_seconds",
+		Help:      "Latency of write requests to the Kubernetes API",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"resource", "verb"})
+
+	pushSources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "push_sources",
+		Help:      "Number of tracked sources with replicate-to annotation",
+	}, []string{"kind"})
+
+	pullSources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pull_sources",
+		Help:      "Number of tracked sources that are referenced by replicate-from annotations",
+	}, []string{"kind"})
+
+	lastSuccessfulSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_successful_sync_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful replication of a source",
+	}, []string{"kind", "source"})
+
+	queueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "queue_depth",
+		Help:      "Number of events waiting in the work queue",
+	}, []string{"queue"})
+
+	queueAdds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "queue_adds_total",
+		Help:      "Number of events added to the work queue",
+	}, []string{"queue"})
+
+	queueRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "queue_retries_total",
+		Help:      "Number of retries of failed events",
+	}, []string{"queue"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		replicationAttempts,
+		replicationSuccesses,
+		replicationFailures,
+		apiWriteDuration,
+		pushSources,
+		pullSources,
+		lastSuccessfulSync,
+		queueDepth,
+		queueAdds,
+		queueRetries,
+	)
+
+	workqueue.SetProvider(queueMetricsProvider{})
+	metrics.Register(apiLatencyMetric{}, noopResultMetric{})
+}
+
+// observeReplication records the outcome of a single replication attempt
+func observeReplication(kind string, mode string, err error) {
+	replicationAttempts.WithLabelValues(kind, mode).Inc()
+	if err != nil {
+		replicationFailures.WithLabelValues(kind, mode).Inc()
+	} else {
+		replicationSuccesses.WithLabelValues(kind, mode).Inc()
+	}
+}
+
+// apiLatencyMetric observes the latency of all write requests issued by the Kubernetes client
+type apiLatencyMetric struct{}
+
+func (apiLatencyMetric) Observe(verb string, u url.URL, latency time.Duration) {
+	switch verb {
+	case "POST", "PUT", "PATCH", "DELETE":
+		apiWriteDuration.WithLabelValues(resourceFromPath(u.Path), verb).Observe(latency.Seconds())
+	}
+}
+
+type noopResultMetric struct{}
+
+func (noopResultMetric) Increment(string, string, string) {}
+
+// resourceFromPath extracts the resource name from a Kubernetes API path like
+// /apis/<group>/<version>/namespaces/<namespace>/<resource>/<name>
+func resourceFromPath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case len(segments) > 2 && segments[0] == "api":
+		segments = segments[2:]
+	case len(segments) > 3 && segments[0] == "apis":
+		segments = segments[3:]
+	default:
+		return "unknown"
+	}
+
+	if len(segments) > 2 && segments[0] == "namespaces" {
+		return segments[2]
+	}
+
+	return segments[0]
+}
+
+// queueMetricsProvider exposes the depth, adds and retries of the replicators' work queues
+type queueMetricsProvider struct{}
+
+func (queueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return queueDepth.WithLabelValues(name)
+}
+
+func (queueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return queueAdds.WithLabelValues(name)
+}
+
+func (queueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return noopMetric{}
+}
+
+func (queueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return noopMetric{}
+}
+
+func (queueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+
+func (queueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return noopMetric{}
+}
+
+func (queueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return queueRetries.WithLabelValues(name)
+}
+
+type noopMetric struct{}
+
+func (noopMetric) Inc()            {}
+func (noopMetric) Dec()            {}
+func (noopMetric) Set(float64)     {}
+func (noopMetric) Observe(float64) {}
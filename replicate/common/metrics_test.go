@@ -0,0 +1,16 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceFromPath(t *testing.T) {
+	assert.Equal(t, "secrets", resourceFromPath("/api/v1/namespaces/default/secrets/foo"))
+	assert.Equal(t, "secrets", resourceFromPath("/api/v1/namespaces/default/secrets"))
+	assert.Equal(t, "roles", resourceFromPath("/apis/rbac.authorization.k8s.io/v1/namespaces/default/roles/foo"))
+	assert.Equal(t, "namespaces", resourceFromPath("/api/v1/namespaces/default"))
+	assert.Equal(t, "clusterroles", resourceFromPath("/apis/rbac.authorization.k8s.io/v1/clusterroles/foo"))
+	assert.Equal(t, "unknown", resourceFromPath("/healthz"))
+}
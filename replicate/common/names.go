@@ -0,0 +1,43 @@
+package common
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// targetNameData is the data the template in ReplicateToNameAnnotation is rendered with
+type targetNameData struct {
+	Name      string
+	Namespace string
+}
+
+// TargetName returns the name of the push replica of source in the given namespace. It is the source's name, unless
+// the source has a ReplicateToNameAnnotation; the annotation may be a template referring to the target namespace as
+// {{ .Namespace }} and to the source's name as {{ .Name }}.
+func TargetName(source metav1.Object, namespace string) (string, error) {
+	name, ok := source.GetAnnotations()[ReplicateToNameAnnotation]
+	if !ok {
+		return source.GetName(), nil
+	}
+
+	tmpl, err := template.New(ReplicateToNameAnnotation).Funcs(templateFuncs).Option("missingkey=error").Parse(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid %s annotation '%s'", ReplicateToNameAnnotation, name)
+	}
+
+	rendered := strings.Builder{}
+	if err := tmpl.Execute(&rendered, targetNameData{Name: source.GetName(), Namespace: namespace}); err != nil {
+		return "", errors.Wrapf(err, "invalid %s annotation '%s'", ReplicateToNameAnnotation, name)
+	}
+
+	if problems := validation.IsDNS1123Subdomain(rendered.String()); len(problems) > 0 {
+		return "", errors.Errorf("invalid %s annotation '%s': '%s' is no valid name: %s",
+			ReplicateToNameAnnotation, name, rendered.String(), strings.Join(problems, ", "))
+	}
+
+	return rendered.String(), nil
+}
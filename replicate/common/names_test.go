@@ -0,0 +1,34 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTargetName(t *testing.T) {
+	source := &metav1.ObjectMeta{Name: "registry-creds-v3", Namespace: "platform"}
+
+	name, err := TargetName(source, "tenant")
+	require.NoError(t, err)
+	require.Equal(t, "registry-creds-v3", name)
+
+	source.Annotations = map[string]string{ReplicateToNameAnnotation: "regcred"}
+	name, err = TargetName(source, "tenant")
+	require.NoError(t, err)
+	require.Equal(t, "regcred", name)
+
+	source.Annotations[ReplicateToNameAnnotation] = `{{ .Namespace }}-{{ .Name | trimSuffix "-v3" }}`
+	name, err = TargetName(source, "tenant")
+	require.NoError(t, err)
+	require.Equal(t, "tenant-registry-creds", name)
+
+	source.Annotations[ReplicateToNameAnnotation] = "{{ .Namespace | upper }}"
+	_, err = TargetName(source, "tenant")
+	require.Error(t, err)
+
+	source.Annotations[ReplicateToNameAnnotation] = "{{ .Namespace"
+	_, err = TargetName(source, "tenant")
+	require.Error(t, err)
+}
@@ -17,13 +17,20 @@ var namespaceWatcher NamespaceWatcher
 
 type AddFunc func(obj *v1.Namespace)
 
+type UpdateFunc func(old *v1.Namespace, new *v1.Namespace)
+
+type DeleteFunc func(obj *v1.Namespace)
+
 type NamespaceWatcher struct {
 	doOnce sync.Once
 
 	NamespaceStore      cache.Store
 	NamespaceController cache.Controller
 
-	AddFuncs []AddFunc
+	AddFuncs    []AddFunc
+	UpdateFuncs []UpdateFunc
+	DeleteFuncs []DeleteFunc
+	funcsLock   sync.RWMutex
 }
 
 // create will create a new namespace if one does not already exist. If it does, it will do nothing.
@@ -31,11 +38,41 @@ func (nw *NamespaceWatcher) create(client kubernetes.Interface, resyncPeriod tim
 	nw.doOnce.Do(func() {
 		namespaceAdded := func(obj interface{}) {
 			namespace := obj.(*v1.Namespace)
+
+			nw.funcsLock.RLock()
+			defer nw.funcsLock.RUnlock()
+
 			for _, addFunc := range nw.AddFuncs {
 				go addFunc(namespace)
 			}
 		}
 
+		namespaceUpdated := func(oldObj interface{}, newObj interface{}) {
+			old := oldObj.(*v1.Namespace)
+			namespace := newObj.(*v1.Namespace)
+
+			nw.funcsLock.RLock()
+			defer nw.funcsLock.RUnlock()
+
+			for _, updateFunc := range nw.UpdateFuncs {
+				go updateFunc(old, namespace)
+			}
+		}
+
+		namespaceDeleted := func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			namespace := obj.(*v1.Namespace)
+
+			nw.funcsLock.RLock()
+			defer nw.funcsLock.RUnlock()
+
+			for _, deleteFunc := range nw.DeleteFuncs {
+				go deleteFunc(namespace)
+			}
+		}
+
 		nw.NamespaceStore, nw.NamespaceController = cache.NewInformer(
 			&cache.ListWatch{
 				ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
@@ -48,7 +85,9 @@ func (nw *NamespaceWatcher) create(client kubernetes.Interface, resyncPeriod tim
 			&v1.Namespace{},
 			resyncPeriod,
 			cache.ResourceEventHandlerFuncs{
-				AddFunc: namespaceAdded,
+				AddFunc:    namespaceAdded,
+				UpdateFunc: namespaceUpdated,
+				DeleteFunc: namespaceDeleted,
 			},
 		)
 
@@ -61,5 +100,51 @@ func (nw *NamespaceWatcher) create(client kubernetes.Interface, resyncPeriod tim
 // OnNamespaceAdded will add another method to a list of functions to be called when a new namespace is created
 func (nw *NamespaceWatcher) OnNamespaceAdded(client kubernetes.Interface, resyncPeriod time.Duration, addFunc AddFunc) {
 	nw.create(client, resyncPeriod)
+
+	nw.funcsLock.Lock()
+	defer nw.funcsLock.Unlock()
+
 	nw.AddFuncs = append(nw.AddFuncs, addFunc)
 }
+
+// OnNamespaceUpdated will add another method to a list of functions to be called when a namespace is updated
+func (nw *NamespaceWatcher) OnNamespaceUpdated(client kubernetes.Interface, resyncPeriod time.Duration, updateFunc UpdateFunc) {
+	nw.create(client, resyncPeriod)
+
+	nw.funcsLock.Lock()
+	defer nw.funcsLock.Unlock()
+
+	nw.UpdateFuncs = append(nw.UpdateFuncs, updateFunc)
+}
+
+// OnNamespaceDeleted will add another method to a list of functions to be called when a namespace is deleted
+func (nw *NamespaceWatcher) OnNamespaceDeleted(client kubernetes.Interface, resyncPeriod time.Duration, deleteFunc DeleteFunc) {
+	nw.create(client, resyncPeriod)
+
+	nw.funcsLock.Lock()
+	defer nw.funcsLock.Unlock()
+
+	nw.DeleteFuncs = append(nw.DeleteFuncs, deleteFunc)
+}
+
+// Namespaces returns all namespaces currently known to the watcher
+func (nw *NamespaceWatcher) Namespaces() []v1.Namespace {
+	objects := nw.NamespaceStore.List()
+	namespaces := make([]v1.Namespace, 0, len(objects))
+
+	for _, obj := range objects {
+		namespaces = append(namespaces, *obj.(*v1.Namespace))
+	}
+
+	return namespaces
+}
+
+// Namespace returns the namespace with the given name. If the namespace is not known (yet), a namespace without
+// any labels is returned, so that it can still be matched by name.
+func (nw *NamespaceWatcher) Namespace(name string) *v1.Namespace {
+	if obj, exists, err := nw.NamespaceStore.GetByKey(name); err == nil && exists {
+		return obj.(*v1.Namespace)
+	}
+
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
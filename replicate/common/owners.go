@@ -0,0 +1,79 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// SourceReference returns the value of the ReplicatedFromLabel of push replicas of the source with the given key.
+// Since label values cannot contain slashes, it is the key with namespace and name separated by a dot (namespaces
+// cannot contain dots, so the reference is still unique), or a hash of the key if that is no valid label value.
+func SourceReference(sourceKey string) string {
+	reference := strings.Replace(sourceKey, "/", ".", 1)
+	if len(validation.IsValidLabelValue(reference)) == 0 {
+		return reference
+	}
+
+	sum := sha256.Sum256([]byte(sourceKey))
+	return hex.EncodeToString(sum[:20])
+}
+
+// SetSourceReference labels target as push replica of source, which is of the replicator's kind. Targets that are
+// about to be created are additionally marked as created by the replicator.
+func (r *GenericReplicator) SetSourceReference(source metav1.Object, target metav1.Object, created bool) {
+	labels := target.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	labels[ReplicatedFromLabel] = SourceReference(MustGetKey(source))
+	labels[ReplicatedFromKindLabel] = r.Kind
+	target.SetLabels(labels)
+
+	if created {
+		annotations := target.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+
+		annotations[CreatedReplicaAnnotation] = "true"
+		target.SetAnnotations(annotations)
+	}
+}
+
+// RemoveSourceReference removes all labels and annotations that mark target as push replica
+func RemoveSourceReference(target metav1.Object) {
+	labels := target.GetLabels()
+	delete(labels, ReplicatedFromLabel)
+	delete(labels, ReplicatedFromKindLabel)
+	target.SetLabels(labels)
+
+	annotations := target.GetAnnotations()
+	delete(annotations, CreatedReplicaAnnotation)
+	target.SetAnnotations(annotations)
+}
+
+// isCreatedReplica checks whether target has been created by the replicator
+func isCreatedReplica(target metav1.Object) bool {
+	created, err := strconv.ParseBool(target.GetAnnotations()[CreatedReplicaAnnotation])
+	return err == nil && created
+}
+
+// IsReplicaOf checks whether target is labeled as push replica of source, which is of the replicator's kind
+func (r *GenericReplicator) IsReplicaOf(target metav1.Object, source metav1.Object) bool {
+	reference, ok := target.GetLabels()[ReplicatedFromLabel]
+	return ok && reference == SourceReference(MustGetKey(source)) && r.isOwnReplica(target)
+}
+
+// isOwnReplica checks whether target is labeled as push replica of a source of the replicator's kind. Different
+// replicators may write the same kind of targets (e.g. Roles and ClusterRoles are both replicated into Roles), so
+// replicas are only ever managed by the replicator of their source's kind.
+func (r *GenericReplicator) isOwnReplica(target metav1.Object) bool {
+	kind, ok := target.GetLabels()[ReplicatedFromKindLabel]
+	return ok && kind == r.Kind
+}
@@ -0,0 +1,16 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceReference(t *testing.T) {
+	require.Equal(t, "platform.registry-creds", SourceReference("platform/registry-creds"))
+	require.Equal(t, "admin", SourceReference("admin"))
+
+	long := SourceReference("platform/a-very-long-name-that-does-not-fit-into-a-label-value-of-63-characters")
+	require.Len(t, long, 40)
+	require.NotEqual(t, long, SourceReference("platform/another-very-long-name-that-does-not-fit-into-a-label-value-of-63-chars"))
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NamespaceSelector selects namespaces by a list of name patterns and/or a label selector. A namespace is selected
+// if it matches any of the patterns or the label selector.
+type NamespaceSelector struct {
+	patterns []*regexp.Regexp
+	labels   labels.Selector
+}
+
+// NewNamespaceSelector builds a selector from the name pattern list stored in the annotation patternAnnotation and
+// the label selector stored in the annotation selectorAnnotation. It returns nil if neither annotation is present.
+func NewNamespaceSelector(annotations map[string]string, patternAnnotation string, selectorAnnotation string) (*NamespaceSelector, error) {
+	patterns, hasPatterns := annotations[patternAnnotation]
+	labelSelector, hasSelector := annotations[selectorAnnotation]
+
+	if !hasPatterns && !hasSelector {
+		return nil, nil
+	}
+
+	selector := NamespaceSelector{}
+
+	if hasPatterns {
+		selector.patterns = StringToPatternList(patterns)
+	}
+
+	if hasSelector {
+		parsed, err := labels.Parse(labelSelector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid label selector '%s' in %s: %v", labelSelector, selectorAnnotation, err)
+		}
+		selector.labels = parsed
+	}
+
+	return &selector, nil
+}
+
+// Matches checks whether namespace is selected
+func (s *NamespaceSelector) Matches(namespace *v1.Namespace) bool {
+	for _, pattern := range s.patterns {
+		if pattern.MatchString(namespace.Name) {
+			return true
+		}
+	}
+
+	return s.labels != nil && s.labels.Matches(labels.Set(namespace.Labels))
+}
+
+// String returns a human readable representation of the selector
+func (s *NamespaceSelector) String() string {
+	out := ""
+	for i, pattern := range s.patterns {
+		if i > 0 {
+			out += ","
+		}
+		out += pattern.String()
+	}
+
+	if s.labels != nil {
+		if out != "" {
+			out += " or "
+		}
+		out += "labels " + s.labels.String()
+	}
+
+	return out
+}
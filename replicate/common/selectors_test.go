@@ -0,0 +1,53 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func namespace(name string, labels map[string]string) *v1.Namespace {
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestNamespaceSelectorWithoutAnnotations(t *testing.T) {
+	selector, err := NewNamespaceSelector(map[string]string{}, ReplicateTo, ReplicateToMatching)
+
+	require.NoError(t, err)
+	require.Nil(t, selector)
+}
+
+func TestNamespaceSelectorMatchesNamesOrLabels(t *testing.T) {
+	selector, err := NewNamespaceSelector(map[string]string{
+		ReplicateTo:         "default, kube-.*",
+		ReplicateToMatching: "team=payments,env!=dev",
+	}, ReplicateTo, ReplicateToMatching)
+	require.NoError(t, err)
+
+	assert.True(t, selector.Matches(namespace("default", nil)))
+	assert.True(t, selector.Matches(namespace("kube-public", nil)))
+	assert.True(t, selector.Matches(namespace("tenant-1", map[string]string{"team": "payments"})))
+	assert.False(t, selector.Matches(namespace("tenant-2", map[string]string{"team": "payments", "env": "dev"})))
+	assert.False(t, selector.Matches(namespace("tenant-3", map[string]string{"team": "search"})))
+}
+
+func TestNamespaceSelectorWithLabelsOnly(t *testing.T) {
+	selector, err := NewNamespaceSelector(map[string]string{
+		ReplicationAllowedNamespacesMatching: "team in (payments, search)",
+	}, ReplicationAllowedNamespaces, ReplicationAllowedNamespacesMatching)
+	require.NoError(t, err)
+
+	assert.True(t, selector.Matches(namespace("tenant-1", map[string]string{"team": "search"})))
+	assert.False(t, selector.Matches(namespace("tenant-1", nil)))
+}
+
+func TestNamespaceSelectorRejectsInvalidLabelSelector(t *testing.T) {
+	_, err := NewNamespaceSelector(map[string]string{
+		ReplicateToMatching: "team in payments",
+	}, ReplicateTo, ReplicateToMatching)
+
+	require.Error(t, err)
+}
@@ -0,0 +1,150 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Values of the status field of ReplicationStatusAnnotation
+const (
+	StatusSynced        = "Synced"
+	StatusDenied        = "Denied"
+	StatusSourceMissing = "SourceMissing"
+	StatusError         = "Error"
+)
+
+// ReplicationStatus is written as JSON into the ReplicationStatusAnnotation of pull-based replication targets
+type ReplicationStatus struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// PushStatus is written as JSON into the PushStatusAnnotation of push-based replication sources
+type PushStatus struct {
+	ReplicatedTo []string `json:"replicatedTo"`
+	Failed       []string `json:"failed,omitempty"`
+}
+
+// replicationStatusFor maps the result of a replication to the status reported on the target
+func replicationStatusFor(err error, successMessage string) ReplicationStatus {
+	switch {
+	case err == nil:
+		return ReplicationStatus{Status: StatusSynced, Message: successMessage}
+	case IsReplicationDenied(err):
+		return ReplicationStatus{Status: StatusDenied, Message: err.Error()}
+	default:
+		return ReplicationStatus{Status: StatusError, Message: err.Error()}
+	}
+}
+
+// setReplicationStatus updates the ReplicationStatusAnnotation of a target, if it changed
+func (r *GenericReplicator) setReplicationStatus(target interface{}, status ReplicationStatus) {
+	value, err := json.Marshal(&status)
+	if err != nil {
+		log.WithField("kind", r.Kind).WithError(err).Errorf("could not encode replication status: %v", err)
+		return
+	}
+
+	r.setAnnotation(target, ReplicationStatusAnnotation, string(value))
+}
+
+// setPushStatus updates the PushStatusAnnotation of a source, if it changed
+func (r *GenericReplicator) setPushStatus(source interface{}, status PushStatus) {
+	sort.Strings(status.ReplicatedTo)
+	sort.Strings(status.Failed)
+
+	value, err := json.Marshal(&status)
+	if err != nil {
+		log.WithField("kind", r.Kind).WithError(err).Errorf("could not encode push status: %v", err)
+		return
+	}
+
+	r.setAnnotation(source, PushStatusAnnotation, string(value))
+}
+
+// setAnnotation patches a single annotation of obj, unless it already has the given value. Failures are logged,
+// since status annotations are informational only.
+func (r *GenericReplicator) setAnnotation(obj interface{}, annotation string, value string) {
+	key := MustGetKey(obj)
+	logger := log.WithField("kind", r.Kind).WithField("resource", key)
+
+	// prefer the cached version, since obj may have been updated in the meantime
+	if current, exists, err := r.Store.GetByKey(key); err == nil && exists {
+		obj = current
+	}
+
+	objectMeta := MustGetObject(obj)
+	if current, ok := objectMeta.GetAnnotations()[annotation]; ok && current == value {
+		return
+	}
+
+	if r.PatchFunc == nil {
+		logger.Debugf("%s does not support patching, not setting %s", r.Kind, annotation)
+		return
+	}
+
+	if err := r.patchAnnotation(objectMeta.GetNamespace(), objectMeta.GetName(), annotation, value); err != nil {
+		logger.WithError(err).Warnf("could not set %s on %s %s: %v", annotation, r.Kind, key, err)
+	}
+}
+
+func (r *GenericReplicator) patchAnnotation(namespace string, name string, annotation string, value string) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{annotation: value},
+		},
+	}
+
+	patchBody, err := json.Marshal(&patch)
+	if err != nil {
+		return errors.Wrapf(err, "error while building patch body: %v", err)
+	}
+
+	obj, err := r.PatchFunc(namespace, name, types.MergePatchType, patchBody)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return r.Store.Update(obj)
+}
+
+// SourceVersion returns the version of source that replicas record in their ReplicatedFromVersionAnnotation. It is
+// derived from the contents of source instead of its resource version, since writing the status annotations changes
+// the resource version of a source without changing anything that is replicated. The status of source is never
+// replicated either, so it is left out as well (e.g. the usage of a resource quota changes all the time).
+func SourceVersion(source runtime.Object) string {
+	obj := source.DeepCopyObject()
+	obj.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+
+	objectMeta := MustGetObject(obj)
+	objectMeta.SetResourceVersion("")
+	objectMeta.SetSelfLink("")
+	objectMeta.SetManagedFields(nil)
+
+	annotations := objectMeta.GetAnnotations()
+	delete(annotations, PushStatusAnnotation)
+	delete(annotations, ReplicationStatusAnnotation)
+	objectMeta.SetAnnotations(annotations)
+
+	var encoded []byte
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err == nil {
+		delete(content, "status")
+		encoded, err = json.Marshal(content)
+	}
+	if err != nil {
+		log.WithError(err).Errorf("could not encode %s to determine its version: %v", MustGetKey(source), err)
+		return MustGetObject(source).GetResourceVersion()
+	}
+
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:16])
+}
@@ -31,13 +31,17 @@ func GetKeysFromStringMap(data map[string]string) []string {
 	return strings
 }
 
-// MustGetKey creates a key from Kubernetes resource in the format <namespace>/<name>
+// MustGetKey creates a key from Kubernetes resource in the format <namespace>/<name>, or <name> for cluster-scoped
+// resources (matching the keys of cache.MetaNamespaceKeyFunc)
 func MustGetKey(obj interface{}) string {
 	if obj == nil {
 		return ""
 	}
 
 	o := MustGetObject(obj)
+	if o.GetNamespace() == "" {
+		return o.GetName()
+	}
 	return fmt.Sprintf("%s/%s", o.GetNamespace(), o.GetName())
 
 }
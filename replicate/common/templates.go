@@ -0,0 +1,102 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// templateFuncs are the functions available in templates, in addition to the text/template builtins. Names and
+// argument order follow the sprig library, so that templates can be moved between this and e.g. Helm charts.
+var templateFuncs = template.FuncMap{
+	"b64enc": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"b64dec": func(s string) (string, error) {
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		return string(decoded), err
+	},
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix string, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix string, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old string, new string, s string) string { return strings.Replace(s, old, new, -1) },
+	"quote":      func(s string) string { return `"` + strings.Replace(s, `"`, `\"`, -1) + `"` },
+	"default": func(def string, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+	"required": func(message string, value string) (string, error) {
+		if value == "" {
+			return "", errors.New(message)
+		}
+		return value, nil
+	},
+	"toJson": func(v interface{}) (string, error) {
+		out, err := json.Marshal(v)
+		return string(out), err
+	},
+}
+
+// Templates renders the keys defined by the TemplateAnnotationPrefix annotations of a target
+type Templates struct {
+	templates map[string]*template.Template
+}
+
+// NewTemplates parses the templates in the annotations of target
+func NewTemplates(target *metav1.ObjectMeta) (*Templates, error) {
+	t := Templates{templates: make(map[string]*template.Template)}
+
+	for annotation, text := range target.Annotations {
+		if !strings.HasPrefix(annotation, TemplateAnnotationPrefix) {
+			continue
+		}
+
+		key := strings.TrimPrefix(annotation, TemplateAnnotationPrefix)
+		parsed, err := template.New(key).Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid template for key %s in %s", key, MustGetKey(target))
+		}
+
+		t.templates[key] = parsed
+	}
+
+	return &t, nil
+}
+
+// Keys returns the sorted keys rendered by the templates
+func (t *Templates) Keys() []string {
+	keys := make([]string, 0, len(t.templates))
+	for key := range t.templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Render executes all templates with data as input. Either all templates are rendered successfully, or an error is
+// returned.
+func (t *Templates) Render(data map[string]string) (map[string]string, error) {
+	rendered := make(map[string]string, len(t.templates))
+
+	for _, key := range t.Keys() {
+		out := bytes.Buffer{}
+		if err := t.templates[key].Execute(&out, data); err != nil {
+			return nil, errors.Wrapf(err, "could not render template for key %s", key)
+		}
+
+		rendered[key] = out.String()
+	}
+
+	return rendered, nil
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplatesRenderDerivedValues(t *testing.T) {
+	templates, err := NewTemplates(meta(map[string]string{
+		TemplateAnnotationPrefix + "jdbc-url":          `jdbc:postgresql://{{ .host }}:{{ .port | default "5432" }}/app?user={{ .user | urlquery }}`,
+		TemplateAnnotationPrefix + ".dockerconfigjson": `{"auths":{"registry.example.com":{"auth":"{{ printf "%s:%s" .user .password | b64enc }}"}}}`,
+		ReplicateFromAnnotation:                        "ns/source",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, []string{".dockerconfigjson", "jdbc-url"}, templates.Keys())
+
+	rendered, err := templates.Render(map[string]string{
+		"host":     "db",
+		"port":     "",
+		"user":     "jane doe",
+		"password": "secret",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "jdbc:postgresql://db:5432/app?user=jane+doe", rendered["jdbc-url"])
+	assert.Equal(t, `{"auths":{"registry.example.com":{"auth":"amFuZSBkb2U6c2VjcmV0"}}}`, rendered[".dockerconfigjson"])
+}
+
+func TestTemplatesFailOnMissingKeys(t *testing.T) {
+	templates, err := NewTemplates(meta(map[string]string{
+		TemplateAnnotationPrefix + "url":  `https://{{ .host }}`,
+		TemplateAnnotationPrefix + "user": `{{ .user | required "user is required" }}`,
+	}))
+	require.NoError(t, err)
+
+	_, err = templates.Render(map[string]string{"user": "jane"})
+	require.Error(t, err)
+
+	_, err = templates.Render(map[string]string{"host": "db", "user": ""})
+	require.Error(t, err)
+}
+
+func TestTemplatesRejectInvalidSyntax(t *testing.T) {
+	_, err := NewTemplates(meta(map[string]string{TemplateAnnotationPrefix + "url": `{{ .host `}))
+
+	require.Error(t, err)
+}
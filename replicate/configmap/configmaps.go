@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/mittwald/kubernetes-replicator/replicate/common"
 	"github.com/pkg/errors"
@@ -29,6 +30,8 @@ func NewReplicator(
 	resyncPeriod time.Duration,
 	allowAll bool,
 	strict bool,
+	maxRetries int,
+	propagation common.MetadataPropagation,
 ) common.Replicator {
 	repl := Replicator{
 		GenericReplicator: common.NewGenericReplicator(common.ReplicatorConfig{
@@ -36,21 +39,39 @@ func NewReplicator(
 			ObjType:      &v1.ConfigMap{},
 			AllowAll:     allowAll,
 			Strict:       strict,
+			MaxRetries:   maxRetries,
 			ResyncPeriod: resyncPeriod,
 			Client:       client,
+			Propagation:  propagation,
 			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
 				return client.CoreV1().ConfigMaps("").List(lo)
 			},
 			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
 				return client.CoreV1().ConfigMaps("").Watch(lo)
 			},
+			PatchFunc: func(namespace string, name string, pt types.PatchType, data []byte) (runtime.Object, error) {
+				return client.CoreV1().ConfigMaps(namespace).Patch(name, pt, data)
+			},
+			SourceKinds: map[string]common.SourceKind{
+				"secret": {
+					ObjType: &v1.Secret{},
+					ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+						return client.CoreV1().Secrets("").List(lo)
+					},
+					WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+						return client.CoreV1().Secrets("").Watch(lo)
+					},
+				},
+			},
 		}),
 	}
 	repl.UpdateFuncs = common.UpdateFuncs{
-		ReplicateDataFrom:        repl.ReplicateDataFrom,
-		ReplicateObjectTo:        repl.ReplicateObjectTo,
-		PatchDeleteDependent:     repl.PatchDeleteDependent,
-		DeleteReplicatedResource: repl.DeleteReplicatedResource,
+		ReplicateDataFrom:         repl.ReplicateDataFrom,
+		ReplicateObjectTo:         repl.ReplicateObjectTo,
+		PatchDeleteDependent:      repl.PatchDeleteDependent,
+		DeleteReplicatedResource:  repl.DeleteReplicatedResource,
+		ReleaseReplicatedResource: repl.ReleaseReplicatedResource,
+		MergeDataFrom:             repl.MergeDataFrom,
 	}
 
 	return &repl
@@ -58,19 +79,93 @@ func NewReplicator(
 
 // ReplicateDataFrom takes a source object and copies over data to target object
 func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interface{}) error {
-	source := sourceObj.(*v1.ConfigMap)
+	return r.MergeDataFrom([]interface{}{sourceObj}, targetObj)
+}
+
+// MergeDataFrom takes several source objects and copies over their data to target object. Keys present in more than
+// one source are taken from the last of them. Sources may be config maps or secrets.
+func (r *Replicator) MergeDataFrom(sourceObjs []interface{}, targetObj interface{}) error {
 	target := targetObj.(*v1.ConfigMap)
+	sources := make([]*v1.ConfigMap, 0, len(sourceObjs))
+	sourceKeys := make([]string, 0, len(sourceObjs))
+	for _, sourceObj := range sourceObjs {
+		switch source := sourceObj.(type) {
+		case *v1.ConfigMap:
+			sources = append(sources, source)
+		case *v1.Secret:
+			converted, err := r.configMapFromSecret(source, target)
+			if err != nil {
+				return errors.Wrapf(err, "could not replicate secret %s to %s", common.MustGetKey(source), common.MustGetKey(target))
+			}
+			sources = append(sources, converted)
+		default:
+			return errors.Errorf("cannot replicate %T into config map %s", sourceObj, common.MustGetKey(target))
+		}
+		sourceKeys = append(sourceKeys, common.MustGetKey(sourceObj))
+	}
 
-	// make sure replication is allowed
 	logger := log.
 		WithField("kind", r.Kind).
-		WithField("source", common.MustGetKey(source)).
+		WithField("source", strings.Join(sourceKeys, ",")).
 		WithField("target", common.MustGetKey(target))
 
+	mapping, err := common.NewKeyMapping(&target.ObjectMeta)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to %s", strings.Join(sourceKeys, ","), common.MustGetKey(target))
+	}
+
+	templates, err := common.NewTemplates(&target.ObjectMeta)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to %s", strings.Join(sourceKeys, ","), common.MustGetKey(target))
+	}
+
+	provenance := common.NewKeyProvenance()
+	inputs := make(map[string]string)
+	names := make([]map[string]string, len(sources))
+	versions := make([]string, len(sources))
+
+	for i, source := range sources {
+		filter, err := common.NewKeyFilter(&source.ObjectMeta, &target.ObjectMeta)
+		if err != nil {
+			return errors.Wrapf(err, "could not replicate %s to %s", common.MustGetKey(source), common.MustGetKey(target))
+		}
+
+		names[i], err = common.ReplicatedKeyNames(filter, mapping,
+			common.GetKeysFromStringMap(source.Data), common.GetKeysFromBinaryMap(source.BinaryData))
+		if err != nil {
+			return errors.Wrapf(err, "could not replicate %s to %s", common.MustGetKey(source), common.MustGetKey(target))
+		}
+
+		provenance.Add(common.MustGetKey(source), names[i])
+		versions[i] = common.SourceVersion(source)
+
+		// templates may use all keys the source permits to be replicated, regardless of the target's filters
+		sourceFilter, err := common.NewKeyFilter(&source.ObjectMeta)
+		if err != nil {
+			return errors.Wrapf(err, "could not replicate %s to %s", common.MustGetKey(source), common.MustGetKey(target))
+		}
+		for key, value := range source.Data {
+			if sourceFilter.Matches(key) {
+				inputs[key] = value
+			}
+		}
+		for key, value := range source.BinaryData {
+			if sourceFilter.Matches(key) {
+				inputs[key] = string(value)
+			}
+		}
+	}
+
+	rendered, err := templates.Render(inputs)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to %s", strings.Join(sourceKeys, ","), common.MustGetKey(target))
+	}
+	provenance.AddDerived(common.RenderedKeyOrigin, templates.Keys()...)
+
 	targetVersion, ok := target.Annotations[common.ReplicatedFromVersionAnnotation]
-	sourceVersion := source.ResourceVersion
+	sourceVersion := strings.Join(versions, ",")
 
-	if ok && targetVersion == sourceVersion && !r.Strict {
+	if ok && targetVersion == sourceVersion && !r.Strict && provenance.UpToDate(&target.ObjectMeta) && renderedUpToDate(target, rendered) {
 		logger.Debugf("target %s is already up-to-date", common.MustGetKey(target))
 		return nil
 	}
@@ -80,42 +175,63 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 	if targetCopy.Data == nil {
 		targetCopy.Data = make(map[string]string)
 	}
+	if targetCopy.BinaryData == nil {
+		targetCopy.BinaryData = make(map[string][]byte)
+	}
 
 	prevKeys, hasPrevKeys := common.PreviouslyPresentKeys(&targetCopy.ObjectMeta)
-	replicatedKeys := make([]string, 0)
 
-	for key, value := range source.Data {
-		targetCopy.Data[key] = value
+	// a key may only be present in either data or binary data, so keys overridden by a later source are removed
+	// from the other map
+	for i, source := range sources {
+		for key, value := range source.Data {
+			name, ok := names[i][key]
+			if !ok {
+				continue
+			}
 
-		replicatedKeys = append(replicatedKeys, key)
-		delete(prevKeys, key)
-	}
+			targetCopy.Data[name] = value
+			delete(targetCopy.BinaryData, name)
+
+			delete(prevKeys, name)
+		}
 
-	if source.BinaryData != nil {
-		targetCopy.BinaryData = make(map[string][]byte)
 		for key, value := range source.BinaryData {
-			targetCopy.BinaryData[key] = value
+			name, ok := names[i][key]
+			if !ok {
+				continue
+			}
 
-			replicatedKeys = append(replicatedKeys, key)
-			delete(prevKeys, key)
+			targetCopy.BinaryData[name] = value
+			delete(targetCopy.Data, name)
+
+			delete(prevKeys, name)
 		}
 	}
 
+	for key, value := range rendered {
+		targetCopy.Data[key] = value
+		delete(targetCopy.BinaryData, key)
+		delete(prevKeys, key)
+	}
+
 	if hasPrevKeys {
 		for k := range prevKeys {
-			logger.Debugf("removing previously present key %s: not present in source any more", k)
+			logger.Debugf("removing previously present key %s: not present in any source or excluded from replication", k)
 			delete(targetCopy.Data, k)
 			delete(targetCopy.BinaryData, k)
 		}
 	}
 
-	sort.Strings(replicatedKeys)
+	if len(targetCopy.BinaryData) == 0 {
+		targetCopy.BinaryData = nil
+	}
 
 	logger.Infof("updating config map %s/%s", target.Namespace, target.Name)
 
 	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
-	targetCopy.Annotations[common.ReplicatedKeysAnnotation] = strings.Join(replicatedKeys, ",")
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = sourceVersion
+	provenance.Apply(targetCopy.Annotations)
 
 	s, err := r.Client.CoreV1().ConfigMaps(target.Namespace).Update(targetCopy)
 	if err != nil {
@@ -127,10 +243,58 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 	return err
 }
 
+// configMapFromSecret converts secret into a config map holding those keys of secret that may be replicated into
+// config maps. Values that are valid UTF-8 are stored in data, all others in binary data.
+func (r *Replicator) configMapFromSecret(secret *v1.Secret, target *v1.ConfigMap) (*v1.ConfigMap, error) {
+	if ok, err := r.IsReplicationPermitted(&target.ObjectMeta, &secret.ObjectMeta); !ok {
+		return nil, errors.Wrapf(err, "replication of target %s is not permitted", common.MustGetKey(secret))
+	}
+
+	allowed, err := common.NewConfigMapAllowedKeysFilter(&secret.ObjectMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := v1.ConfigMap{
+		ObjectMeta: *secret.ObjectMeta.DeepCopy(),
+		Data:       make(map[string]string),
+		BinaryData: make(map[string][]byte),
+	}
+
+	for key, value := range secret.Data {
+		if !allowed.Matches(key) {
+			continue
+		}
+
+		if utf8.Valid(value) {
+			converted.Data[key] = string(value)
+		} else {
+			converted.BinaryData[key] = append([]byte(nil), value...)
+		}
+	}
+
+	return &converted, nil
+}
+
+// renderedUpToDate checks whether target contains the rendered values
+func renderedUpToDate(target *v1.ConfigMap, rendered map[string]string) bool {
+	for key, value := range rendered {
+		if current, ok := target.Data[key]; !ok || current != value {
+			return false
+		}
+	}
+
+	return true
+}
+
 // ReplicateObjectTo copies the whole object to target namespace
 func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
 	source := sourceObj.(*v1.ConfigMap)
-	targetLocation := fmt.Sprintf("%s/%s", target.Name, source.Name)
+	targetName, err := common.TargetName(source, target.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to namespace %s", common.MustGetKey(source), target.Name)
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
 
 	logger := log.
 		WithField("kind", r.Kind).
@@ -143,13 +307,30 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 	}
 	logger.Infof("Checking if %s exists? %v", targetLocation, exists)
 
+	var targetMeta *metav1.ObjectMeta
+	if exists {
+		targetMeta = &targetResource.(*v1.ConfigMap).ObjectMeta
+	}
+
+	filter, err := common.NewKeyFilter(&source.ObjectMeta, targetMeta)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to %s", common.MustGetKey(source), targetLocation)
+	}
+
+	names, err := common.ReplicatedKeyNames(filter, nil, common.GetKeysFromStringMap(source.Data), common.GetKeysFromBinaryMap(source.BinaryData))
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to %s", common.MustGetKey(source), targetLocation)
+	}
+
 	var resourceCopy *v1.ConfigMap
 	if exists {
 		targetObject := targetResource.(*v1.ConfigMap)
 		targetVersion, ok := targetObject.Annotations[common.ReplicatedFromVersionAnnotation]
-		sourceVersion := source.ResourceVersion
+		sourceVersion := common.SourceVersion(source)
 
-		if ok && targetVersion == sourceVersion {
+		if ok && targetVersion == sourceVersion && r.IsReplicaOf(targetObject, source) &&
+			common.ReplicatedKeysUpToDate(&targetObject.ObjectMeta, names) &&
+			r.Propagation.UpToDate(&source.ObjectMeta, &targetObject.ObjectMeta) {
 			logger.Debugf("Secret %s is already up-to-date", common.MustGetKey(targetObject))
 			return nil
 		}
@@ -173,32 +354,45 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 	replicatedKeys := make([]string, 0)
 
 	for key, value := range source.Data {
-		resourceCopy.Data[key] = value
+		name, ok := names[key]
+		if !ok {
+			continue
+		}
 
-		replicatedKeys = append(replicatedKeys, key)
-		delete(prevKeys, key)
+		resourceCopy.Data[name] = value
+
+		replicatedKeys = append(replicatedKeys, name)
+		delete(prevKeys, name)
 	}
 	for key, value := range source.BinaryData {
+		name, ok := names[key]
+		if !ok {
+			continue
+		}
+
 		newValue := make([]byte, len(value))
 		copy(newValue, value)
-		resourceCopy.BinaryData[key] = newValue
+		resourceCopy.BinaryData[name] = newValue
 
-		replicatedKeys = append(replicatedKeys, key)
-		delete(prevKeys, key)
+		replicatedKeys = append(replicatedKeys, name)
+		delete(prevKeys, name)
 	}
 
 	if hasPrevKeys {
 		for k := range prevKeys {
-			logger.Debugf("removing previously present key %s: not present in source secret any more", k)
+			logger.Debugf("removing previously present key %s: not present in source config map or excluded from replication", k)
 			delete(resourceCopy.Data, k)
+			delete(resourceCopy.BinaryData, k)
 		}
 	}
 
 	sort.Strings(replicatedKeys)
-	resourceCopy.Name = source.Name
+	resourceCopy.Name = targetName
 	resourceCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	resourceCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	resourceCopy.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
 	resourceCopy.Annotations[common.ReplicatedKeysAnnotation] = strings.Join(replicatedKeys, ",")
+	r.Propagation.Apply(&source.ObjectMeta, &resourceCopy.ObjectMeta)
+	r.SetSourceReference(source, resourceCopy, !exists)
 
 	var obj interface{}
 	if exists {
@@ -269,7 +463,7 @@ func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error
 
 	if strings.Join(resourceKeys, ",") == object.Annotations[common.ReplicatedKeysAnnotation] {
 		logger.Debugf("Deleting %s", targetLocation)
-		if err := r.Client.CoreV1().Secrets(object.Namespace).Delete(object.Name, &metav1.DeleteOptions{}); err != nil {
+		if err := r.Client.CoreV1().ConfigMaps(object.Namespace).Delete(object.Name, &metav1.DeleteOptions{}); err != nil {
 			return errors.Wrapf(err, "Failed deleting %s: %v", targetLocation, err)
 		}
 	} else {
@@ -278,3 +472,28 @@ func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error
 
 	return nil
 }
+
+// ReleaseReplicatedResource removes the replicated keys and the source reference from a config map that already
+// existed when it was first replicated into
+func (r *Replicator) ReleaseReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	object := targetResource.(*v1.ConfigMap)
+
+	released := object.DeepCopy()
+	if keys, ok := common.PreviouslyPresentKeys(&released.ObjectMeta); ok {
+		for k := range keys {
+			delete(released.Data, k)
+			delete(released.BinaryData, k)
+		}
+	}
+	delete(released.Annotations, common.ReplicatedKeysAnnotation)
+	common.RemoveSourceReference(released)
+
+	log.WithField("kind", r.Kind).WithField("target", targetLocation).Debugf("Releasing %s", targetLocation)
+	s, err := r.Client.CoreV1().ConfigMaps(object.Namespace).Update(released)
+	if err != nil {
+		return errors.Wrapf(err, "Failed updating %s: %v", targetLocation, err)
+	}
+
+	return r.Store.Update(s)
+}
@@ -0,0 +1,81 @@
+package limitrange
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func defaultLimits(namespace string, annotations map[string]string) *v1.LimitRange {
+	return &v1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "default",
+			Namespace:       namespace,
+			ResourceVersion: "1",
+			Annotations:     annotations,
+		},
+		Spec: v1.LimitRangeSpec{
+			Limits: []v1.LimitRangeItem{{
+				Type:    v1.LimitTypeContainer,
+				Default: v1.ResourceList{v1.ResourceMemory: resource.MustParse("256Mi")},
+			}},
+		},
+	}
+}
+
+func TestLimitRangeIsPushedIntoNamespace(t *testing.T) {
+	source := defaultLimits("baseline", map[string]string{common.ReplicateTo: "tenant"})
+
+	client := fake.NewSimpleClientset(source)
+	repl := NewReplicator(client, time.Minute, false, false, 5).(*Replicator)
+
+	require.NoError(t, repl.ReplicateObjectTo(source, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}}))
+
+	replica, err := client.CoreV1().LimitRanges("tenant").Get("default", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, source.Spec, replica.Spec)
+	require.True(t, repl.IsReplicaOf(replica, source))
+	require.NotContains(t, replica.Annotations, common.ReplicateTo)
+}
+
+func TestLimitRangeIsPulledFromSource(t *testing.T) {
+	source := defaultLimits("baseline", map[string]string{common.ReplicationAllowed: "true"})
+	target := &v1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "default",
+			Namespace:   "tenant",
+			Annotations: map[string]string{common.ReplicateFromAnnotation: "baseline/default"},
+		},
+	}
+
+	client := fake.NewSimpleClientset(source, target)
+	repl := NewReplicator(client, time.Minute, false, false, 5).(*Replicator)
+
+	require.Error(t, repl.ReplicateDataFrom(source, target), "replication must be allowed by the source")
+
+	source.Annotations[common.ReplicationAllowedNamespaces] = "tenant"
+	require.NoError(t, repl.ReplicateDataFrom(source, target))
+
+	replica, err := client.CoreV1().LimitRanges("tenant").Get("default", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, source.Spec, replica.Spec)
+	require.Equal(t, common.SourceVersion(source), replica.Annotations[common.ReplicatedFromVersionAnnotation])
+}
+
+func TestDeletedSourceKeepsLimitsOfDependents(t *testing.T) {
+	dependent := defaultLimits("tenant", map[string]string{common.ReplicateFromAnnotation: "baseline/default"})
+
+	client := fake.NewSimpleClientset(dependent)
+	repl := NewReplicator(client, time.Minute, false, false, 5).(*Replicator)
+
+	patched, err := repl.PatchDeleteDependent("baseline/default", dependent)
+	require.NoError(t, err)
+	require.Equal(t, dependent, patched)
+	require.Empty(t, client.Actions())
+}
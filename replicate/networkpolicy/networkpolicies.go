@@ -0,0 +1,239 @@
+package networkpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Replicator struct {
+	*common.GenericReplicator
+}
+
+// NewReplicator creates a new network policy replicator
+func NewReplicator(
+	client kubernetes.Interface,
+	resyncPeriod time.Duration,
+	allowAll bool,
+	strict bool,
+	maxRetries int,
+) common.Replicator {
+	repl := Replicator{
+		GenericReplicator: common.NewGenericReplicator(common.ReplicatorConfig{
+			Kind:         "NetworkPolicy",
+			ObjType:      &networkingv1.NetworkPolicy{},
+			AllowAll:     allowAll,
+			Strict:       strict,
+			MaxRetries:   maxRetries,
+			ResyncPeriod: resyncPeriod,
+			Client:       client,
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.NetworkingV1().NetworkPolicies("").List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.NetworkingV1().NetworkPolicies("").Watch(lo)
+			},
+			PatchFunc: func(namespace string, name string, pt types.PatchType, data []byte) (runtime.Object, error) {
+				return client.NetworkingV1().NetworkPolicies(namespace).Patch(name, pt, data)
+			},
+		}),
+	}
+	repl.UpdateFuncs = common.UpdateFuncs{
+		ReplicateDataFrom:         repl.ReplicateDataFrom,
+		ReplicateObjectTo:         repl.ReplicateObjectTo,
+		PatchDeleteDependent:      repl.PatchDeleteDependent,
+		DeleteReplicatedResource:  repl.DeleteReplicatedResource,
+		ReleaseReplicatedResource: repl.ReleaseReplicatedResource,
+	}
+
+	return &repl
+}
+
+func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interface{}) error {
+	source := sourceObj.(*networkingv1.NetworkPolicy)
+	target := targetObj.(*networkingv1.NetworkPolicy)
+
+	logger := log.
+		WithField("kind", r.Kind).
+		WithField("source", common.MustGetKey(source)).
+		WithField("target", common.MustGetKey(target))
+
+	// make sure replication is allowed
+	if ok, err := r.IsReplicationPermitted(&target.ObjectMeta, &source.ObjectMeta); !ok {
+		return errors.Wrapf(err, "replication of target %s is not permitted", common.MustGetKey(source))
+	}
+
+	targetVersion, ok := target.Annotations[common.ReplicatedFromVersionAnnotation]
+	sourceVersion := common.SourceVersion(source)
+
+	if ok && targetVersion == sourceVersion && !r.Strict {
+		logger.Debugf("target %s is already up-to-date", common.MustGetKey(target))
+		return nil
+	}
+
+	targetCopy := target.DeepCopy()
+	if targetCopy.Annotations == nil {
+		targetCopy.Annotations = make(map[string]string)
+	}
+
+	source.Spec.DeepCopyInto(&targetCopy.Spec)
+
+	logger.Infof("updating target %s/%s", target.Namespace, target.Name)
+
+	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
+
+	s, err := r.Client.NetworkingV1().NetworkPolicies(target.Namespace).Update(targetCopy)
+	if err != nil {
+		err = errors.Wrapf(err, "Failed updating target %s/%s", target.Namespace, targetCopy.Name)
+	} else if err = r.Store.Update(s); err != nil {
+		err = errors.Wrapf(err, "Failed to update cache for %s/%s: %v", target.Namespace, targetCopy.Name, err)
+	}
+
+	return err
+}
+
+// ReplicateObjectTo copies the whole object to target namespace
+func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
+	source := sourceObj.(*networkingv1.NetworkPolicy)
+	targetName, err := common.TargetName(source, target.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to namespace %s", common.MustGetKey(source), target.Name)
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
+
+	logger := log.
+		WithField("kind", r.Kind).
+		WithField("source", common.MustGetKey(source)).
+		WithField("target", targetLocation)
+
+	targetResource, exists, err := r.Store.GetByKey(targetLocation)
+	if err != nil {
+		return errors.Wrapf(err, "Could not get %s from cache!", targetLocation)
+	}
+	logger.Infof("Checking if %s exists? %v", targetLocation, exists)
+
+	var targetCopy *networkingv1.NetworkPolicy
+	if exists {
+		targetObject := targetResource.(*networkingv1.NetworkPolicy)
+		targetVersion, ok := targetObject.Annotations[common.ReplicatedFromVersionAnnotation]
+		sourceVersion := common.SourceVersion(source)
+
+		if ok && targetVersion == sourceVersion && r.IsReplicaOf(targetObject, source) {
+			logger.Debugf("NetworkPolicy %s is already up-to-date", common.MustGetKey(targetObject))
+			return nil
+		}
+
+		targetCopy = targetObject.DeepCopy()
+	} else {
+		targetCopy = new(networkingv1.NetworkPolicy)
+	}
+
+	if targetCopy.Annotations == nil {
+		targetCopy.Annotations = make(map[string]string)
+	}
+
+	targetCopy.Name = targetName
+	source.Spec.DeepCopyInto(&targetCopy.Spec)
+	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
+	r.SetSourceReference(source, targetCopy, !exists)
+
+	var obj interface{}
+	if exists {
+		logger.Debugf("Updating existing network policy %s/%s", target.Name, targetCopy.Name)
+		obj, err = r.Client.NetworkingV1().NetworkPolicies(target.Name).Update(targetCopy)
+	} else {
+		logger.Debugf("Creating a new network policy %s/%s", target.Name, targetCopy.Name)
+		obj, err = r.Client.NetworkingV1().NetworkPolicies(target.Name).Create(targetCopy)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Failed to update network policy %s/%s", target.Name, targetCopy.Name)
+	}
+
+	if err := r.Store.Update(obj); err != nil {
+		return errors.Wrapf(err, "Failed to update cache for %s/%s", target.Name, targetCopy.Name)
+	}
+
+	return nil
+}
+
+// PatchDeleteDependent clears the spec of a network policy whose source has been deleted. The cleared policy still
+// selects all pods of its namespace, so that ingress traffic to them is denied rather than allowed.
+func (r *Replicator) PatchDeleteDependent(sourceKey string, target interface{}) (interface{}, error) {
+	dependentKey := common.MustGetKey(target)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"source": sourceKey,
+		"target": dependentKey,
+	})
+
+	targetObject, ok := target.(*networkingv1.NetworkPolicy)
+	if !ok {
+		err := errors.Errorf("bad type returned from Store: %T", target)
+		return nil, err
+	}
+
+	patch := []common.JSONPatchOperation{{Operation: "replace", Path: "/spec", Value: networkingv1.NetworkPolicySpec{}}}
+	patchBody, err := json.Marshal(&patch)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while building patch body for network policy %s: %v", dependentKey, err)
+	}
+
+	logger.Debugf("clearing dependent network policy %s", dependentKey)
+	logger.Tracef("patch body: %s", string(patchBody))
+
+	s, err := r.Client.NetworkingV1().NetworkPolicies(targetObject.Namespace).Patch(targetObject.Name, types.JSONPatchType, patchBody)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while patching network policy %s: %v", dependentKey, err)
+	}
+	return s, nil
+}
+
+// DeleteReplicatedResource deletes a resource replicated by ReplicateTo annotation
+func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"target": targetLocation,
+	})
+
+	object := targetResource.(*networkingv1.NetworkPolicy)
+	logger.Debugf("Deleting %s", targetLocation)
+	if err := r.Client.NetworkingV1().NetworkPolicies(object.Namespace).Delete(object.Name, &metav1.DeleteOptions{}); err != nil {
+		return errors.Wrapf(err, "Failed deleting %s: %v", targetLocation, err)
+	}
+
+	return nil
+}
+
+// ReleaseReplicatedResource removes the replicated spec and the source reference from a network policy that already
+// existed when it was first replicated into
+func (r *Replicator) ReleaseReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	object := targetResource.(*networkingv1.NetworkPolicy)
+
+	released := object.DeepCopy()
+	released.Spec = networkingv1.NetworkPolicySpec{}
+	common.RemoveSourceReference(released)
+
+	log.WithField("kind", r.Kind).WithField("target", targetLocation).Debugf("Releasing %s", targetLocation)
+	s, err := r.Client.NetworkingV1().NetworkPolicies(object.Namespace).Update(released)
+	if err != nil {
+		return errors.Wrapf(err, "Failed updating %s: %v", targetLocation, err)
+	}
+
+	return r.Store.Update(s)
+}
@@ -0,0 +1,86 @@
+package networkpolicy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func denyIngress(namespace string, annotations map[string]string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "default-deny",
+			Namespace:       namespace,
+			ResourceVersion: "1",
+			Annotations:     annotations,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+func TestNetworkPolicyIsPushedIntoNamespace(t *testing.T) {
+	source := denyIngress("baseline", map[string]string{common.ReplicateTo: "tenant"})
+
+	client := fake.NewSimpleClientset(source)
+	repl := NewReplicator(client, time.Minute, false, false, 5).(*Replicator)
+
+	require.NoError(t, repl.ReplicateObjectTo(source, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}}))
+
+	replica, err := client.NetworkingV1().NetworkPolicies("tenant").Get("default-deny", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, source.Spec, replica.Spec)
+	require.True(t, repl.IsReplicaOf(replica, source))
+	require.NotContains(t, replica.Annotations, common.ReplicateTo)
+}
+
+func TestNetworkPolicyIsPulledFromSource(t *testing.T) {
+	source := denyIngress("baseline", map[string]string{common.ReplicationAllowed: "true"})
+	source.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{
+		From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+	}}
+	target := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "default-deny",
+			Namespace:   "tenant",
+			Annotations: map[string]string{common.ReplicateFromAnnotation: "baseline/default-deny"},
+		},
+	}
+
+	client := fake.NewSimpleClientset(source, target)
+	repl := NewReplicator(client, time.Minute, false, false, 5).(*Replicator)
+
+	require.Error(t, repl.ReplicateDataFrom(source, target), "replication must be allowed by the source")
+
+	source.Annotations[common.ReplicationAllowedNamespaces] = "tenant"
+	require.NoError(t, repl.ReplicateDataFrom(source, target))
+
+	replica, err := client.NetworkingV1().NetworkPolicies("tenant").Get("default-deny", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, source.Spec, replica.Spec)
+	require.Equal(t, common.SourceVersion(source), replica.Annotations[common.ReplicatedFromVersionAnnotation])
+}
+
+func TestDeletedSourceClearsSpecOfDependents(t *testing.T) {
+	dependent := denyIngress("tenant", map[string]string{common.ReplicateFromAnnotation: "baseline/default-deny"})
+	dependent.Spec.PodSelector = metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+
+	client := fake.NewSimpleClientset(dependent)
+	repl := NewReplicator(client, time.Minute, false, false, 5).(*Replicator)
+
+	patched, err := repl.PatchDeleteDependent("baseline/default-deny", dependent)
+	require.NoError(t, err)
+	require.Equal(t, networkingv1.NetworkPolicySpec{}, patched.(*networkingv1.NetworkPolicy).Spec)
+
+	cleared, err := client.NetworkingV1().NetworkPolicies("tenant").Get("default-deny", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, networkingv1.NetworkPolicySpec{}, cleared.Spec)
+	require.Equal(t, "baseline/default-deny", cleared.Annotations[common.ReplicateFromAnnotation])
+}
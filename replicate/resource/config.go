@@ -0,0 +1,101 @@
+package resource
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Config describes a resource type that is replicated using the dynamic client
+type Config struct {
+	Resource schema.GroupVersionResource
+
+	// Paths lists the fields that are copied from source to target; each path is a list of nested field names
+	Paths [][]string
+}
+
+// builtinResources lists the resources that have a replicator of their own. Replicating them using the dynamic client
+// as well would let two replicators fight over the same objects.
+var builtinResources = []schema.GroupResource{
+	{Resource: "secrets"},
+	{Resource: "configmaps"},
+	{Resource: "serviceaccounts"},
+	{Resource: "limitranges"},
+	{Resource: "resourcequotas"},
+	{Group: "rbac.authorization.k8s.io", Resource: "roles"},
+	{Group: "rbac.authorization.k8s.io", Resource: "rolebindings"},
+	{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"},
+	{Group: "networking.k8s.io", Resource: "networkpolicies"},
+	{Group: "extensions", Resource: "networkpolicies"},
+}
+
+// ParseConfig parses a resource configuration in the format <group>/<version>/<resource>:<path>[,<path>...].
+// The group is omitted for resources of the core group (<version>/<resource>); nested fields are separated by dots,
+// e.g. cert-manager.io/v1alpha2/issuers:spec or v1/podtemplates:template.spec. Resources that have a built-in
+// replicator are rejected.
+func ParseConfig(value string) (Config, error) {
+	config := Config{}
+
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+		return config, errors.Errorf("invalid resource '%s': expected '<group>/<version>/<resource>:<path>[,<path>...]'", value)
+	}
+
+	gvr := strings.Split(strings.TrimSpace(parts[0]), "/")
+	switch len(gvr) {
+	case 2:
+		config.Resource = schema.GroupVersionResource{Version: gvr[0], Resource: gvr[1]}
+	case 3:
+		config.Resource = schema.GroupVersionResource{Group: gvr[0], Version: gvr[1], Resource: gvr[2]}
+	default:
+		return config, errors.Errorf("invalid resource '%s': expected '<group>/<version>/<resource>' or '<version>/<resource>'", parts[0])
+	}
+
+	for _, builtin := range builtinResources {
+		if config.Resource.GroupResource() == builtin {
+			return config, errors.Errorf("invalid resource '%s': %s are replicated by a built-in replicator", parts[0], builtin)
+		}
+	}
+
+	for _, p := range strings.Split(parts[1], ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		path := strings.Split(p, ".")
+		for _, field := range path {
+			if field == "" {
+				return config, errors.Errorf("invalid path '%s' in resource '%s'", p, value)
+			}
+		}
+
+		if path[0] == "metadata" || path[0] == "status" || path[0] == "apiVersion" || path[0] == "kind" {
+			return config, errors.Errorf("invalid path '%s' in resource '%s': %s cannot be replicated", p, value, path[0])
+		}
+
+		config.Paths = append(config.Paths, path)
+	}
+
+	if len(config.Paths) == 0 {
+		return config, errors.Errorf("invalid resource '%s': no paths to replicate", value)
+	}
+
+	return config, nil
+}
+
+// String returns the configuration in the format accepted by ParseConfig
+func (c Config) String() string {
+	paths := make([]string, len(c.Paths))
+	for i, path := range c.Paths {
+		paths[i] = strings.Join(path, ".")
+	}
+
+	gvr := c.Resource.Version + "/" + c.Resource.Resource
+	if c.Resource.Group != "" {
+		gvr = c.Resource.Group + "/" + gvr
+	}
+
+	return gvr + ":" + strings.Join(paths, ",")
+}
@@ -0,0 +1,298 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Replicator replicates arbitrary namespaced resources by copying a configured set of fields
+type Replicator struct {
+	*common.GenericReplicator
+	resource dynamic.NamespaceableResourceInterface
+	config   Config
+}
+
+// NewReplicator creates a new replicator for the resource described by config
+func NewReplicator(
+	client kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+	config Config,
+	resyncPeriod time.Duration,
+	allowAll bool,
+	strict bool,
+	maxRetries int,
+) common.Replicator {
+	resource := dynamicClient.Resource(config.Resource)
+
+	repl := Replicator{
+		GenericReplicator: common.NewGenericReplicator(common.ReplicatorConfig{
+			Kind:         config.Resource.GroupResource().String(),
+			ObjType:      &unstructured.Unstructured{},
+			AllowAll:     allowAll,
+			Strict:       strict,
+			MaxRetries:   maxRetries,
+			ResyncPeriod: resyncPeriod,
+			Client:       client,
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return resource.List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return resource.Watch(lo)
+			},
+			PatchFunc: func(namespace string, name string, pt types.PatchType, data []byte) (runtime.Object, error) {
+				return resource.Namespace(namespace).Patch(name, pt, data, metav1.PatchOptions{})
+			},
+		}),
+		resource: resource,
+		config:   config,
+	}
+	repl.UpdateFuncs = common.UpdateFuncs{
+		ReplicateDataFrom:         repl.ReplicateDataFrom,
+		ReplicateObjectTo:         repl.ReplicateObjectTo,
+		PatchDeleteDependent:      repl.PatchDeleteDependent,
+		DeleteReplicatedResource:  repl.DeleteReplicatedResource,
+		ReleaseReplicatedResource: repl.ReleaseReplicatedResource,
+	}
+
+	return &repl
+}
+
+// ReplicateDataFrom takes a source object and copies over the configured fields to target object
+func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interface{}) error {
+	source := sourceObj.(*unstructured.Unstructured)
+	target := targetObj.(*unstructured.Unstructured)
+
+	logger := log.
+		WithField("kind", r.Kind).
+		WithField("source", common.MustGetKey(source)).
+		WithField("target", common.MustGetKey(target))
+
+	// make sure replication is allowed
+	if ok, err := r.IsReplicationPermitted(objectMeta(target), objectMeta(source)); !ok {
+		return errors.Wrapf(err, "replication of target %s is not permitted", common.MustGetKey(source))
+	}
+
+	targetVersion, ok := target.GetAnnotations()[common.ReplicatedFromVersionAnnotation]
+	sourceVersion := common.SourceVersion(source)
+
+	if ok && targetVersion == sourceVersion && !r.Strict {
+		logger.Debugf("target %s is already up-to-date", common.MustGetKey(target))
+		return nil
+	}
+
+	targetCopy := target.DeepCopy()
+	if err := r.copyFields(source, targetCopy); err != nil {
+		return err
+	}
+
+	logger.Infof("updating target %s/%s", target.GetNamespace(), target.GetName())
+
+	setReplicatedAnnotations(targetCopy, source)
+
+	s, err := r.resource.Namespace(target.GetNamespace()).Update(targetCopy, metav1.UpdateOptions{})
+	if err != nil {
+		err = errors.Wrapf(err, "Failed updating target %s/%s", target.GetNamespace(), targetCopy.GetName())
+	} else if err = r.Store.Update(s); err != nil {
+		err = errors.Wrapf(err, "Failed to update cache for %s/%s: %v", target.GetNamespace(), targetCopy.GetName(), err)
+	}
+
+	return err
+}
+
+// ReplicateObjectTo copies the whole object to target namespace
+func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
+	source := sourceObj.(*unstructured.Unstructured)
+	targetName, err := common.TargetName(source, target.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to namespace %s", common.MustGetKey(source), target.Name)
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
+
+	logger := log.
+		WithField("kind", r.Kind).
+		WithField("source", common.MustGetKey(source)).
+		WithField("target", targetLocation)
+
+	targetResource, exists, err := r.Store.GetByKey(targetLocation)
+	if err != nil {
+		return errors.Wrapf(err, "Could not get %s from cache!", targetLocation)
+	}
+	logger.Infof("Checking if %s exists? %v", targetLocation, exists)
+
+	var targetCopy *unstructured.Unstructured
+	if exists {
+		targetObject := targetResource.(*unstructured.Unstructured)
+		targetVersion, ok := targetObject.GetAnnotations()[common.ReplicatedFromVersionAnnotation]
+		sourceVersion := common.SourceVersion(source)
+
+		if ok && targetVersion == sourceVersion && r.IsReplicaOf(targetObject, source) {
+			logger.Debugf("%s %s is already up-to-date", r.Kind, common.MustGetKey(targetObject))
+			return nil
+		}
+
+		targetCopy = targetObject.DeepCopy()
+	} else {
+		targetCopy = new(unstructured.Unstructured)
+		targetCopy.SetAPIVersion(r.config.Resource.GroupVersion().String())
+		targetCopy.SetKind(source.GetKind())
+		targetCopy.SetNamespace(target.Name)
+		targetCopy.SetName(targetName)
+	}
+
+	if err := r.copyFields(source, targetCopy); err != nil {
+		return err
+	}
+
+	setReplicatedAnnotations(targetCopy, source)
+	r.SetSourceReference(source, targetCopy, !exists)
+
+	var obj interface{}
+	if exists {
+		logger.Debugf("Updating existing %s %s", r.Kind, targetLocation)
+		obj, err = r.resource.Namespace(target.Name).Update(targetCopy, metav1.UpdateOptions{})
+	} else {
+		logger.Debugf("Creating a new %s %s", r.Kind, targetLocation)
+		obj, err = r.resource.Namespace(target.Name).Create(targetCopy, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Failed to update %s %s", r.Kind, targetLocation)
+	}
+
+	if err := r.Store.Update(obj); err != nil {
+		return errors.Wrapf(err, "Failed to update cache for %s", targetLocation)
+	}
+
+	return nil
+}
+
+// PatchDeleteDependent clears the replicated fields of a target whose source has been deleted
+func (r *Replicator) PatchDeleteDependent(sourceKey string, target interface{}) (interface{}, error) {
+	dependentKey := common.MustGetKey(target)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"source": sourceKey,
+		"target": dependentKey,
+	})
+
+	targetObject, ok := target.(*unstructured.Unstructured)
+	if !ok {
+		err := errors.Errorf("bad type returned from Store: %T", target)
+		return nil, err
+	}
+
+	patch := make(map[string]interface{})
+	for _, path := range r.config.Paths {
+		if err := unstructured.SetNestedField(patch, nil, path...); err != nil {
+			return nil, errors.Wrapf(err, "error while building patch body for %s %s: %v", r.Kind, dependentKey, err)
+		}
+	}
+
+	patchBody, err := json.Marshal(&patch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while building patch body for %s %s: %v", r.Kind, dependentKey, err)
+	}
+
+	logger.Debugf("clearing dependent %s %s", r.Kind, dependentKey)
+	logger.Tracef("patch body: %s", string(patchBody))
+
+	s, err := r.resource.Namespace(targetObject.GetNamespace()).
+		Patch(targetObject.GetName(), types.MergePatchType, patchBody, metav1.PatchOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while patching %s %s: %v", r.Kind, dependentKey, err)
+	}
+
+	return s, nil
+}
+
+// DeleteReplicatedResource deletes a resource replicated by ReplicateTo annotation
+func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"target": targetLocation,
+	})
+
+	object := targetResource.(*unstructured.Unstructured)
+	logger.Debugf("Deleting %s", targetLocation)
+	if err := r.resource.Namespace(object.GetNamespace()).Delete(object.GetName(), &metav1.DeleteOptions{}); err != nil {
+		return errors.Wrapf(err, "Failed deleting %s: %v", targetLocation, err)
+	}
+
+	return nil
+}
+
+// ReleaseReplicatedResource removes the replicated fields and the source reference from a target that already existed
+// when it was first replicated into
+func (r *Replicator) ReleaseReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	object := targetResource.(*unstructured.Unstructured)
+
+	released := object.DeepCopy()
+	for _, path := range r.config.Paths {
+		unstructured.RemoveNestedField(released.Object, path...)
+	}
+	common.RemoveSourceReference(released)
+
+	log.WithField("kind", r.Kind).WithField("target", targetLocation).Debugf("Releasing %s", targetLocation)
+	s, err := r.resource.Namespace(object.GetNamespace()).Update(released, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "Failed updating %s: %v", targetLocation, err)
+	}
+
+	return r.Store.Update(s)
+}
+
+// copyFields copies all configured fields from source to target. Fields missing in source are removed from target.
+func (r *Replicator) copyFields(source *unstructured.Unstructured, target *unstructured.Unstructured) error {
+	for _, path := range r.config.Paths {
+		value, found, err := unstructured.NestedFieldCopy(source.Object, path...)
+		if err != nil {
+			return errors.Wrapf(err, "Could not read field %v of %s %s: %v", path, r.Kind, common.MustGetKey(source), err)
+		}
+
+		if !found {
+			unstructured.RemoveNestedField(target.Object, path...)
+			continue
+		}
+
+		if err := unstructured.SetNestedField(target.Object, value, path...); err != nil {
+			return errors.Wrapf(err, "Could not set field %v of %s %s: %v", path, r.Kind, common.MustGetKey(target), err)
+		}
+	}
+
+	return nil
+}
+
+func setReplicatedAnnotations(target *unstructured.Unstructured, source *unstructured.Unstructured) {
+	annotations := target.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
+	target.SetAnnotations(annotations)
+}
+
+// objectMeta returns the parts of the object's metadata that are relevant for replication permission checks
+func objectMeta(obj *unstructured.Unstructured) *metav1.ObjectMeta {
+	return &metav1.ObjectMeta{
+		Name:        obj.GetName(),
+		Namespace:   obj.GetNamespace(),
+		Annotations: obj.GetAnnotations(),
+		Labels:      obj.GetLabels(),
+	}
+}
@@ -0,0 +1,92 @@
+package resource
+
+import (
+	"testing"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig("cert-manager.io/v1alpha2/issuers:spec")
+	require.NoError(t, err)
+	assert.Equal(t, schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1alpha2", Resource: "issuers"}, config.Resource)
+	assert.Equal(t, [][]string{{"spec"}}, config.Paths)
+	assert.Equal(t, "cert-manager.io/v1alpha2/issuers:spec", config.String())
+
+	config, err = ParseConfig("v1/podtemplates:template.spec, data")
+	require.NoError(t, err)
+	assert.Equal(t, schema.GroupVersionResource{Version: "v1", Resource: "podtemplates"}, config.Resource)
+	assert.Equal(t, [][]string{{"template", "spec"}, {"data"}}, config.Paths)
+
+	for _, invalid := range []string{
+		"issuers:spec",
+		"cert-manager.io/v1alpha2/issuers",
+		"cert-manager.io/v1alpha2/issuers:",
+		"v1/podtemplates:template..spec",
+		"v1/podtemplates:metadata.labels",
+	} {
+		_, err := ParseConfig(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseConfigRejectsBuiltinResources(t *testing.T) {
+	for _, builtin := range []string{
+		"v1/secrets:data",
+		"v1/configmaps:data",
+		"v1/serviceaccounts:imagePullSecrets",
+		"v1/limitranges:spec",
+		"v1/resourcequotas:spec",
+		"rbac.authorization.k8s.io/v1/roles:rules",
+		"rbac.authorization.k8s.io/v1beta1/rolebindings:subjects",
+		"rbac.authorization.k8s.io/v1/clusterroles:rules",
+		"networking.k8s.io/v1/networkpolicies:spec",
+		"extensions/v1beta1/networkpolicies:spec",
+	} {
+		_, err := ParseConfig(builtin)
+		assert.Error(t, err, builtin)
+	}
+}
+
+func issuer(namespace string, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetAPIVersion("cert-manager.io/v1alpha2")
+	obj.SetKind("Issuer")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetResourceVersion("1")
+	return obj
+}
+
+func TestReplicateObjectToCopiesConfiguredPaths(t *testing.T) {
+	config, err := ParseConfig("cert-manager.io/v1alpha2/issuers:spec")
+	require.NoError(t, err)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	repl := NewReplicator(fake.NewSimpleClientset(), dynamicClient, config, 0, true, false, 5).(*Replicator)
+
+	source := issuer("default", "self-signed", map[string]interface{}{
+		"selfSigned": map[string]interface{}{},
+	})
+	source.SetLabels(map[string]string{"not": "replicated"})
+
+	require.NoError(t, repl.ReplicateObjectTo(source, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}}))
+
+	target, err := dynamicClient.Resource(config.Resource).Namespace("tenant").Get("self-signed", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, source.Object["spec"], target.Object["spec"])
+	assert.Equal(t, map[string]string{
+		common.ReplicatedFromLabel:     "default.self-signed",
+		common.ReplicatedFromKindLabel: "issuers.cert-manager.io",
+	}, target.GetLabels())
+	assert.Equal(t, common.SourceVersion(source), target.GetAnnotations()[common.ReplicatedFromVersionAnnotation])
+}
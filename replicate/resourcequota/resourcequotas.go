@@ -0,0 +1,306 @@
+package resourcequota
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Replicator struct {
+	*common.GenericReplicator
+}
+
+// NewReplicator creates a new resource quota replicator
+func NewReplicator(
+	client kubernetes.Interface,
+	resyncPeriod time.Duration,
+	allowAll bool,
+	strict bool,
+	maxRetries int,
+) common.Replicator {
+	repl := Replicator{
+		GenericReplicator: common.NewGenericReplicator(common.ReplicatorConfig{
+			Kind:         "ResourceQuota",
+			ObjType:      &v1.ResourceQuota{},
+			AllowAll:     allowAll,
+			Strict:       strict,
+			MaxRetries:   maxRetries,
+			ResyncPeriod: resyncPeriod,
+			Client:       client,
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().ResourceQuotas("").List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().ResourceQuotas("").Watch(lo)
+			},
+			PatchFunc: func(namespace string, name string, pt types.PatchType, data []byte) (runtime.Object, error) {
+				return client.CoreV1().ResourceQuotas(namespace).Patch(name, pt, data)
+			},
+		}),
+	}
+	repl.UpdateFuncs = common.UpdateFuncs{
+		ReplicateDataFrom:         repl.ReplicateDataFrom,
+		ReplicateObjectTo:         repl.ReplicateObjectTo,
+		PatchDeleteDependent:      repl.PatchDeleteDependent,
+		DeleteReplicatedResource:  repl.DeleteReplicatedResource,
+		ReleaseReplicatedResource: repl.ReleaseReplicatedResource,
+	}
+
+	return &repl
+}
+
+// ReplicateDataFrom takes a source object and copies over its spec to target object, applying the quota overrides of
+// the target namespace
+func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interface{}) error {
+	source := sourceObj.(*v1.ResourceQuota)
+	target := targetObj.(*v1.ResourceQuota)
+
+	logger := log.
+		WithField("kind", r.Kind).
+		WithField("source", common.MustGetKey(source)).
+		WithField("target", common.MustGetKey(target))
+
+	// make sure replication is allowed
+	if ok, err := r.IsReplicationPermitted(&target.ObjectMeta, &source.ObjectMeta); !ok {
+		return errors.Wrapf(err, "replication of target %s is not permitted", common.MustGetKey(source))
+	}
+
+	overrides, err := quotaOverrides(r.Namespace(target.Namespace))
+	if err != nil {
+		return errors.Wrapf(err, "could not apply overrides to %s", common.MustGetKey(target))
+	}
+
+	targetVersion, ok := target.Annotations[common.ReplicatedFromVersionAnnotation]
+	sourceVersion := common.SourceVersion(source)
+
+	if ok && targetVersion == sourceVersion && overridesApplied(target, overrides) && !r.Strict {
+		logger.Debugf("target %s is already up-to-date", common.MustGetKey(target))
+		return nil
+	}
+
+	targetCopy := target.DeepCopy()
+	if targetCopy.Annotations == nil {
+		targetCopy.Annotations = make(map[string]string)
+	}
+
+	copySpec(source, targetCopy, overrides)
+
+	logger.Infof("updating target %s/%s", target.Namespace, target.Name)
+
+	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
+
+	s, err := r.Client.CoreV1().ResourceQuotas(target.Namespace).Update(targetCopy)
+	if err != nil {
+		err = errors.Wrapf(err, "Failed updating target %s/%s", target.Namespace, targetCopy.Name)
+	} else if err = r.Store.Update(s); err != nil {
+		err = errors.Wrapf(err, "Failed to update cache for %s/%s: %v", target.Namespace, targetCopy.Name, err)
+	}
+
+	return err
+}
+
+// ReplicateObjectTo copies the whole object to target namespace, applying the quota overrides of the namespace
+func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
+	source := sourceObj.(*v1.ResourceQuota)
+	targetName, err := common.TargetName(source, target.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to namespace %s", common.MustGetKey(source), target.Name)
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
+
+	logger := log.
+		WithField("kind", r.Kind).
+		WithField("source", common.MustGetKey(source)).
+		WithField("target", targetLocation)
+
+	targetResource, exists, err := r.Store.GetByKey(targetLocation)
+	if err != nil {
+		return errors.Wrapf(err, "Could not get %s from cache!", targetLocation)
+	}
+	logger.Infof("Checking if %s exists? %v", targetLocation, exists)
+
+	overrides, err := quotaOverrides(target)
+	if err != nil {
+		return errors.Wrapf(err, "could not apply overrides to %s", targetLocation)
+	}
+
+	var targetCopy *v1.ResourceQuota
+	if exists {
+		targetObject := targetResource.(*v1.ResourceQuota)
+		targetVersion, ok := targetObject.Annotations[common.ReplicatedFromVersionAnnotation]
+		sourceVersion := common.SourceVersion(source)
+
+		if ok && targetVersion == sourceVersion && r.IsReplicaOf(targetObject, source) &&
+			overridesApplied(targetObject, overrides) {
+			logger.Debugf("ResourceQuota %s is already up-to-date", common.MustGetKey(targetObject))
+			return nil
+		}
+
+		targetCopy = targetObject.DeepCopy()
+	} else {
+		targetCopy = new(v1.ResourceQuota)
+	}
+
+	if targetCopy.Annotations == nil {
+		targetCopy.Annotations = make(map[string]string)
+	}
+
+	targetCopy.Name = targetName
+	copySpec(source, targetCopy, overrides)
+	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
+	r.SetSourceReference(source, targetCopy, !exists)
+
+	var obj interface{}
+	if exists {
+		logger.Debugf("Updating existing resource quota %s/%s", target.Name, targetCopy.Name)
+		obj, err = r.Client.CoreV1().ResourceQuotas(target.Name).Update(targetCopy)
+	} else {
+		logger.Debugf("Creating a new resource quota %s/%s", target.Name, targetCopy.Name)
+		obj, err = r.Client.CoreV1().ResourceQuotas(target.Name).Create(targetCopy)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Failed to update resource quota %s/%s", target.Name, targetCopy.Name)
+	}
+
+	if err := r.Store.Update(obj); err != nil {
+		return errors.Wrapf(err, "Failed to update cache for %s/%s", target.Name, targetCopy.Name)
+	}
+
+	return nil
+}
+
+// PatchDeleteDependent leaves dependents of a deleted resource quota untouched, so that the target namespace does not
+// lose its resource limits.
+func (r *Replicator) PatchDeleteDependent(sourceKey string, target interface{}) (interface{}, error) {
+	dependentKey := common.MustGetKey(target)
+
+	if _, ok := target.(*v1.ResourceQuota); !ok {
+		err := errors.Errorf("bad type returned from Store: %T", target)
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"source": sourceKey,
+		"target": dependentKey,
+	}).Infof("source %s has been deleted, keeping last replicated spec of resource quota %s", sourceKey, dependentKey)
+
+	return target, nil
+}
+
+// DeleteReplicatedResource deletes a resource replicated by ReplicateTo annotation
+func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"target": targetLocation,
+	})
+
+	object := targetResource.(*v1.ResourceQuota)
+	logger.Debugf("Deleting %s", targetLocation)
+	if err := r.Client.CoreV1().ResourceQuotas(object.Namespace).Delete(object.Name, &metav1.DeleteOptions{}); err != nil {
+		return errors.Wrapf(err, "Failed deleting %s: %v", targetLocation, err)
+	}
+
+	return nil
+}
+
+// ReleaseReplicatedResource removes the source reference from a resource quota that already existed when it was first
+// replicated into. Like PatchDeleteDependent, it keeps the replicated spec.
+func (r *Replicator) ReleaseReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	object := targetResource.(*v1.ResourceQuota)
+
+	released := object.DeepCopy()
+	common.RemoveSourceReference(released)
+
+	log.WithField("kind", r.Kind).WithField("target", targetLocation).Debugf("Releasing %s", targetLocation)
+	s, err := r.Client.CoreV1().ResourceQuotas(object.Namespace).Update(released)
+	if err != nil {
+		return errors.Wrapf(err, "Failed updating %s: %v", targetLocation, err)
+	}
+
+	return r.Store.Update(s)
+}
+
+// quotaOverrides parses the ResourceQuotaOverridesAnnotation of namespace, which contains a comma separated list of
+// <resource>=<quantity> pairs, e.g. "pods=50,requests.cpu=10"
+func quotaOverrides(namespace *v1.Namespace) (v1.ResourceList, error) {
+	overrides := make(v1.ResourceList)
+
+	value, ok := namespace.Annotations[common.ResourceQuotaOverridesAnnotation]
+	if !ok {
+		return overrides, nil
+	}
+
+	for _, override := range strings.Split(value, ",") {
+		override = strings.TrimSpace(override)
+		if override == "" {
+			continue
+		}
+
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid quota override '%s' in namespace %s: expected <resource>=<quantity>",
+				override, namespace.Name)
+		}
+
+		quantity, err := resource.ParseQuantity(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid quota override '%s' in namespace %s: %v", override, namespace.Name, err)
+		}
+
+		overrides[v1.ResourceName(strings.TrimSpace(parts[0]))] = quantity
+	}
+
+	return overrides, nil
+}
+
+// formatOverrides returns a canonical representation of overrides, as recorded in AppliedQuotaOverridesAnnotation
+func formatOverrides(overrides v1.ResourceList) string {
+	values := make([]string, 0, len(overrides))
+	for name, quantity := range overrides {
+		values = append(values, fmt.Sprintf("%s=%s", name, quantity.String()))
+	}
+	sort.Strings(values)
+
+	return strings.Join(values, ",")
+}
+
+// overridesApplied checks whether target was replicated using the given overrides
+func overridesApplied(target *v1.ResourceQuota, overrides v1.ResourceList) bool {
+	return target.Annotations[common.AppliedQuotaOverridesAnnotation] == formatOverrides(overrides)
+}
+
+// copySpec copies the spec of source into target and applies overrides to the hard limits
+func copySpec(source *v1.ResourceQuota, target *v1.ResourceQuota, overrides v1.ResourceList) {
+	source.Spec.DeepCopyInto(&target.Spec)
+
+	if len(overrides) == 0 {
+		delete(target.Annotations, common.AppliedQuotaOverridesAnnotation)
+		return
+	}
+
+	if target.Spec.Hard == nil {
+		target.Spec.Hard = make(v1.ResourceList)
+	}
+	for name, quantity := range overrides {
+		target.Spec.Hard[name] = quantity.DeepCopy()
+	}
+
+	target.Annotations[common.AppliedQuotaOverridesAnnotation] = formatOverrides(overrides)
+}
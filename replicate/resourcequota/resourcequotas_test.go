@@ -0,0 +1,127 @@
+package resourcequota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func namespaceWithOverrides(overrides string) *v1.Namespace {
+	return &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "tenant",
+			Annotations: map[string]string{common.ResourceQuotaOverridesAnnotation: overrides},
+		},
+	}
+}
+
+func TestQuotaOverridesAreApplied(t *testing.T) {
+	overrides, err := quotaOverrides(namespaceWithOverrides("pods=50, requests.cpu=10"))
+	require.NoError(t, err)
+
+	source := &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "baseline", ResourceVersion: "1"},
+		Spec: v1.ResourceQuotaSpec{
+			Hard: v1.ResourceList{
+				v1.ResourcePods:     resource.MustParse("10"),
+				v1.ResourceServices: resource.MustParse("5"),
+			},
+		},
+	}
+	target := &v1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}
+
+	copySpec(source, target, overrides)
+
+	assert.Equal(t, "50", target.Spec.Hard.Pods().String())
+	services := target.Spec.Hard[v1.ResourceServices]
+	cpu := target.Spec.Hard[v1.ResourceRequestsCPU]
+	assert.Equal(t, "5", services.String())
+	assert.Equal(t, "10", cpu.String())
+	assert.Equal(t, "10", source.Spec.Hard.Pods().String())
+
+	assert.Equal(t, "pods=50,requests.cpu=10", target.Annotations[common.AppliedQuotaOverridesAnnotation])
+	assert.True(t, overridesApplied(target, overrides))
+	assert.False(t, overridesApplied(target, v1.ResourceList{}))
+}
+
+func TestInvalidQuotaOverrides(t *testing.T) {
+	_, err := quotaOverrides(namespaceWithOverrides("pods"))
+	assert.Error(t, err)
+
+	_, err = quotaOverrides(namespaceWithOverrides("pods=many"))
+	assert.Error(t, err)
+}
+
+func TestRemovedQuotaOverridesAreReverted(t *testing.T) {
+	source := &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "default",
+			Namespace:       "baseline",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{common.ReplicateTo: "tenant"},
+		},
+		Spec: v1.ResourceQuotaSpec{Hard: v1.ResourceList{v1.ResourcePods: resource.MustParse("10")}},
+	}
+
+	client := fake.NewSimpleClientset(source)
+	repl := NewReplicator(client, time.Minute, false, false, 5).(*Replicator)
+
+	require.NoError(t, repl.ReplicateObjectTo(source, namespaceWithOverrides("pods=50")))
+
+	replica, err := client.CoreV1().ResourceQuotas("tenant").Get("default", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "50", replica.Spec.Hard.Pods().String())
+	assert.Equal(t, "pods=50", replica.Annotations[common.AppliedQuotaOverridesAnnotation])
+
+	// the overrides annotation is removed from the namespace
+	require.NoError(t, repl.ReplicateObjectTo(source, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}}))
+
+	replica, err = client.CoreV1().ResourceQuotas("tenant").Get("default", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "10", replica.Spec.Hard.Pods().String())
+	assert.NotContains(t, replica.Annotations, common.AppliedQuotaOverridesAnnotation)
+}
+
+func TestStatusUpdatesOfTheSourceDoNotUpdateReplicas(t *testing.T) {
+	source := &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "default",
+			Namespace:       "baseline",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{common.ReplicateTo: "tenant"},
+		},
+		Spec: v1.ResourceQuotaSpec{Hard: v1.ResourceList{v1.ResourcePods: resource.MustParse("10")}},
+	}
+	tenant := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}}
+
+	client := fake.NewSimpleClientset(source)
+	repl := NewReplicator(client, time.Minute, false, false, 5).(*Replicator)
+	require.NoError(t, repl.ReplicateObjectTo(source, tenant))
+
+	// the usage of the source changes whenever pods are started or stopped in its namespace
+	used := source.DeepCopy()
+	used.ResourceVersion = "2"
+	used.Status = v1.ResourceQuotaStatus{
+		Hard: v1.ResourceList{v1.ResourcePods: resource.MustParse("10")},
+		Used: v1.ResourceList{v1.ResourcePods: resource.MustParse("3")},
+	}
+
+	client.ClearActions()
+	require.NoError(t, repl.ReplicateObjectTo(used, tenant))
+	require.Empty(t, client.Actions())
+
+	changed := used.DeepCopy()
+	changed.ResourceVersion = "3"
+	changed.Spec.Hard[v1.ResourcePods] = resource.MustParse("20")
+
+	require.NoError(t, repl.ReplicateObjectTo(changed, tenant))
+	require.Len(t, client.Actions(), 1)
+	require.True(t, client.Actions()[0].Matches("update", "resourcequotas"))
+}
@@ -28,6 +28,7 @@ func NewReplicator(
 	resyncPeriod time.Duration,
 	allowAll bool,
 	strict bool,
+	maxRetries int,
 ) common.Replicator {
 	repl := Replicator{
 		GenericReplicator: common.NewGenericReplicator(common.ReplicatorConfig{
@@ -35,6 +36,7 @@ func NewReplicator(
 			ObjType:      &rbacv1.Role{},
 			AllowAll:     allowAll,
 			Strict:       strict,
+			MaxRetries:   maxRetries,
 			ResyncPeriod: resyncPeriod,
 			Client:       client,
 			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
@@ -43,13 +45,17 @@ func NewReplicator(
 			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
 				return client.RbacV1().Roles("").Watch(lo)
 			},
+			PatchFunc: func(namespace string, name string, pt types.PatchType, data []byte) (runtime.Object, error) {
+				return client.RbacV1().Roles(namespace).Patch(name, pt, data)
+			},
 		}),
 	}
 	repl.UpdateFuncs = common.UpdateFuncs{
-		ReplicateDataFrom:        repl.ReplicateDataFrom,
-		ReplicateObjectTo:        repl.ReplicateObjectTo,
-		PatchDeleteDependent:     repl.PatchDeleteDependent,
-		DeleteReplicatedResource: repl.DeleteReplicatedResource,
+		ReplicateDataFrom:         repl.ReplicateDataFrom,
+		ReplicateObjectTo:         repl.ReplicateObjectTo,
+		PatchDeleteDependent:      repl.PatchDeleteDependent,
+		DeleteReplicatedResource:  repl.DeleteReplicatedResource,
+		ReleaseReplicatedResource: repl.ReleaseReplicatedResource,
 	}
 
 	return &repl
@@ -70,7 +76,7 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 	}
 
 	targetVersion, ok := target.Annotations[common.ReplicatedFromVersionAnnotation]
-	sourceVersion := source.ResourceVersion
+	sourceVersion := common.SourceVersion(source)
 
 	if ok && targetVersion == sourceVersion && !r.Strict {
 		logger.Debugf("target %s is already up-to-date", common.MustGetKey(target))
@@ -84,7 +90,7 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 	logger.Infof("updating target %s/%s", target.Namespace, target.Name)
 
 	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
 
 	s, err := r.Client.RbacV1().Roles(target.Namespace).Update(targetCopy)
 	if err != nil {
@@ -99,7 +105,11 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 // ReplicateObjectTo copies the whole object to target namespace
 func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
 	source := sourceObj.(*rbacv1.Role)
-	targetLocation := fmt.Sprintf("%s/%s", target.Name, source.Name)
+	targetName, err := common.TargetName(source, target.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to namespace %s", common.MustGetKey(source), target.Name)
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
 
 	logger := log.
 		WithField("kind", r.Kind).
@@ -116,9 +126,9 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 	if exists {
 		targetObject := targetResource.(*rbacv1.Role)
 		targetVersion, ok := targetObject.Annotations[common.ReplicatedFromVersionAnnotation]
-		sourceVersion := source.ResourceVersion
+		sourceVersion := common.SourceVersion(source)
 
-		if ok && targetVersion == sourceVersion {
+		if ok && targetVersion == sourceVersion && r.IsReplicaOf(targetObject, source) {
 			logger.Debugf("Role %s is already up-to-date", common.MustGetKey(targetObject))
 			return nil
 		}
@@ -135,10 +145,11 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 		targetCopy.Annotations = make(map[string]string)
 	}
 
-	targetCopy.Name = source.Name
+	targetCopy.Name = targetName
 	targetCopy.Rules = source.Rules
 	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
+	r.SetSourceReference(source, targetCopy, !exists)
 
 	var obj interface{}
 	if exists {
@@ -200,8 +211,27 @@ func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error
 
 	object := targetResource.(*rbacv1.Role)
 	logger.Debugf("Deleting %s", targetLocation)
-	if err := r.Client.CoreV1().Secrets(object.Namespace).Delete(object.Name, &metav1.DeleteOptions{}); err != nil {
+	if err := r.Client.RbacV1().Roles(object.Namespace).Delete(object.Name, &metav1.DeleteOptions{}); err != nil {
 		return errors.Wrapf(err, "Failed deleting %s: %v", targetLocation, err)
 	}
 	return nil
 }
+
+// ReleaseReplicatedResource removes the replicated rules and the source reference from a role that already existed when
+// it was first replicated into
+func (r *Replicator) ReleaseReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	object := targetResource.(*rbacv1.Role)
+
+	released := object.DeepCopy()
+	released.Rules = nil
+	common.RemoveSourceReference(released)
+
+	log.WithField("kind", r.Kind).WithField("target", targetLocation).Debugf("Releasing %s", targetLocation)
+	s, err := r.Client.RbacV1().Roles(object.Namespace).Update(released)
+	if err != nil {
+		return errors.Wrapf(err, "Failed updating %s: %v", targetLocation, err)
+	}
+
+	return r.Store.Update(s)
+}
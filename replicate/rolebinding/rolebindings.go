@@ -3,31 +3,42 @@ package rolebinding
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/mittwald/kubernetes-replicator/replicate/common"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
-
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
 type Replicator struct {
 	*common.GenericReplicator
+
+	// roleStore caches the roles referenced by role bindings, which are replicated along with them if the
+	// ReplicateRoleAnnotation is set
+	roleStore      cache.Store
+	roleController cache.Controller
+	roleOnce       sync.Once
 }
 
-// NewReplicator creates a new secret replicator
+// NewReplicator creates a new role binding replicator
 func NewReplicator(
 	client kubernetes.Interface,
 	resyncPeriod time.Duration,
 	allowAll bool,
 	strict bool,
+	maxRetries int,
 ) common.Replicator {
 	repl := Replicator{
 		GenericReplicator: common.NewGenericReplicator(common.ReplicatorConfig{
@@ -35,6 +46,7 @@ func NewReplicator(
 			ObjType:      &rbacv1.RoleBinding{},
 			AllowAll:     allowAll,
 			Strict:       strict,
+			MaxRetries:   maxRetries,
 			ResyncPeriod: resyncPeriod,
 			Client:       client,
 			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
@@ -43,18 +55,74 @@ func NewReplicator(
 			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
 				return client.RbacV1().RoleBindings("").Watch(lo)
 			},
+			PatchFunc: func(namespace string, name string, pt types.PatchType, data []byte) (runtime.Object, error) {
+				return client.RbacV1().RoleBindings(namespace).Patch(name, pt, data)
+			},
 		}),
 	}
+	repl.roleStore, repl.roleController = cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.RbacV1().Roles("").List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.RbacV1().Roles("").Watch(lo)
+			},
+		},
+		&rbacv1.Role{},
+		resyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: repl.roleChanged,
+			UpdateFunc: func(old interface{}, new interface{}) {
+				if old.(*rbacv1.Role).ResourceVersion != new.(*rbacv1.Role).ResourceVersion {
+					repl.roleChanged(new)
+				}
+			},
+		},
+	)
 	repl.UpdateFuncs = common.UpdateFuncs{
-		ReplicateDataFrom:        repl.ReplicateDataFrom,
-		ReplicateObjectTo:        repl.ReplicateObjectTo,
-		PatchDeleteDependent:     repl.PatchDeleteDependent,
-		DeleteReplicatedResource: repl.DeleteReplicatedResource,
+		ReplicateDataFrom:         repl.ReplicateDataFrom,
+		ReplicateObjectTo:         repl.ReplicateObjectTo,
+		PatchDeleteDependent:      repl.PatchDeleteDependent,
+		DeleteReplicatedResource:  repl.DeleteReplicatedResource,
+		ReleaseReplicatedResource: repl.ReleaseReplicatedResource,
 	}
 
 	return &repl
 }
 
+// StartInformer starts the informers for role bindings and for the roles they reference
+func (r *Replicator) StartInformer() {
+	r.GenericReplicator.StartInformer()
+	r.roleOnce.Do(func() {
+		go r.roleController.Run(wait.NeverStop)
+	})
+}
+
+// Synced checks whether the caches of role bindings and roles have been synced
+func (r *Replicator) Synced() bool {
+	return r.GenericReplicator.Synced() && r.roleController.HasSynced()
+}
+
+// Run starts the informers and processes queued events until the process terminates
+func (r *Replicator) Run() {
+	r.StartInformer()
+	r.GenericReplicator.Run()
+}
+
+// roleChanged queues the role bindings that replicate role along with them, so that its replicas are updated
+func (r *Replicator) roleChanged(obj interface{}) {
+	role := obj.(*rbacv1.Role)
+
+	for _, obj := range r.Store.List() {
+		binding := obj.(*rbacv1.RoleBinding)
+		if binding.Namespace == role.Namespace && binding.RoleRef.Kind == "Role" && binding.RoleRef.Name == role.Name &&
+			isRoleReplicated(binding) {
+			r.Queue.Add(common.MustGetKey(binding))
+		}
+	}
+}
+
 func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interface{}) error {
 	source := sourceObj.(*rbacv1.RoleBinding)
 	target := targetObj.(*rbacv1.RoleBinding)
@@ -69,8 +137,12 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 		return errors.Wrapf(err, "replication of target %s is not permitted", common.MustGetKey(source))
 	}
 
+	if err := r.ensureRole(source, target.Namespace); err != nil {
+		return err
+	}
+
 	targetVersion, ok := target.Annotations[common.ReplicatedFromVersionAnnotation]
-	sourceVersion := source.ResourceVersion
+	sourceVersion := common.SourceVersion(source)
 
 	if ok && targetVersion == sourceVersion && !r.Strict {
 		logger.Debugf("target %s/%s is already up-to-date", target.Namespace, target.Name)
@@ -83,9 +155,22 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 	log.Infof("updating target %s/%s", target.Namespace, target.Name)
 
 	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
+
+	var s *rbacv1.RoleBinding
+	var err error
+	if target.RoleRef != source.RoleRef {
+		if !isRecreateAllowed(source, target) {
+			return errors.Errorf("roleRef of target %s differs from source %s and cannot be changed; "+
+				"set the %s annotation to recreate it", common.MustGetKey(target), common.MustGetKey(source),
+				common.RecreateOnRoleRefChangeAnnotation)
+		}
 
-	s, err := r.Client.RbacV1().RoleBindings(target.Namespace).Update(targetCopy)
+		targetCopy.RoleRef = source.RoleRef
+		s, err = r.recreate(target, targetCopy)
+	} else {
+		s, err = r.Client.RbacV1().RoleBindings(target.Namespace).Update(targetCopy)
+	}
 	if err != nil {
 		err = errors.Wrapf(err, "Failed updating target %s/%s", target.Namespace, targetCopy.Name)
 	} else if err = r.Store.Update(s); err != nil {
@@ -98,13 +183,21 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 // ReplicateObjectTo copies the whole object to target namespace
 func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
 	source := sourceObj.(*rbacv1.RoleBinding)
-	targetLocation := fmt.Sprintf("%s/%s", target.Name, source.Name)
+	targetName, err := common.TargetName(source, target.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to namespace %s", common.MustGetKey(source), target.Name)
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
 
 	logger := log.
 		WithField("kind", r.Kind).
 		WithField("source", common.MustGetKey(source)).
 		WithField("target", targetLocation)
 
+	if err := r.ensureRole(source, target.Name); err != nil {
+		return err
+	}
+
 	targetResource, exists, err := r.Store.GetByKey(targetLocation)
 	if err != nil {
 		return errors.Wrapf(err, "Could not get %s from cache!", targetLocation)
@@ -112,16 +205,26 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 	logger.Infof("Checking if %s exists? %v", targetLocation, exists)
 
 	var targetCopy *rbacv1.RoleBinding
+	recreate := false
 	if exists {
 		targetObject := targetResource.(*rbacv1.RoleBinding)
 		targetVersion, ok := targetObject.Annotations[common.ReplicatedFromVersionAnnotation]
-		sourceVersion := source.ResourceVersion
+		sourceVersion := common.SourceVersion(source)
 
-		if ok && targetVersion == sourceVersion {
+		if ok && targetVersion == sourceVersion && r.IsReplicaOf(targetObject, source) {
 			logger.Debugf("RoleBinding %s is already up-to-date", common.MustGetKey(targetObject))
 			return nil
 		}
 
+		if targetObject.RoleRef != source.RoleRef {
+			if !isRecreateAllowed(source, targetObject) {
+				return errors.Errorf("roleRef of %s differs from source %s and cannot be changed; "+
+					"set the %s annotation to recreate it", targetLocation, common.MustGetKey(source),
+					common.RecreateOnRoleRefChangeAnnotation)
+			}
+			recreate = true
+		}
+
 		targetCopy = targetObject.DeepCopy()
 	} else {
 		targetCopy = new(rbacv1.RoleBinding)
@@ -131,14 +234,18 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 		targetCopy.Annotations = make(map[string]string)
 	}
 
-	targetCopy.Name = source.Name
+	targetCopy.Name = targetName
 	targetCopy.Subjects = source.Subjects
 	targetCopy.RoleRef = source.RoleRef
 	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
+	r.SetSourceReference(source, targetCopy, !exists)
 
 	var obj interface{}
-	if exists {
+	if recreate {
+		logger.Debugf("Recreating roleBinding %s/%s with changed roleRef", target.Name, targetCopy.Name)
+		obj, err = r.recreate(targetResource.(*rbacv1.RoleBinding), targetCopy)
+	} else if exists {
 		logger.Debugf("Updating existing roleBinding %s/%s", target.Name, targetCopy.Name)
 		obj, err = r.Client.RbacV1().RoleBindings(target.Name).Update(targetCopy)
 	} else {
@@ -198,8 +305,154 @@ func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error
 
 	object := targetResource.(*rbacv1.RoleBinding)
 	logger.Debugf("Deleting %s", targetLocation)
-	if err := r.Client.CoreV1().Secrets(object.Namespace).Delete(object.Name, &metav1.DeleteOptions{}); err != nil {
+	if err := r.Client.RbacV1().RoleBindings(object.Namespace).Delete(object.Name, &metav1.DeleteOptions{}); err != nil {
 		return errors.Wrapf(err, "Failed deleting %s: %v", targetLocation, err)
 	}
 	return nil
 }
+
+// ReleaseReplicatedResource removes the replicated subjects and the source reference from a role binding that already
+// existed when it was first replicated into
+func (r *Replicator) ReleaseReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	object := targetResource.(*rbacv1.RoleBinding)
+
+	released := object.DeepCopy()
+	released.Subjects = nil
+	common.RemoveSourceReference(released)
+
+	log.WithField("kind", r.Kind).WithField("target", targetLocation).Debugf("Releasing %s", targetLocation)
+	s, err := r.Client.RbacV1().RoleBindings(object.Namespace).Update(released)
+	if err != nil {
+		return errors.Wrapf(err, "Failed updating %s: %v", targetLocation, err)
+	}
+
+	return r.Store.Update(s)
+}
+
+// isRecreateAllowed checks whether the source or the target opted in to recreating the target when its roleRef
+// needs to change
+func isRecreateAllowed(source *rbacv1.RoleBinding, target *rbacv1.RoleBinding) bool {
+	for _, annotations := range []map[string]string{source.Annotations, target.Annotations} {
+		if allowed, err := strconv.ParseBool(annotations[common.RecreateOnRoleRefChangeAnnotation]); err == nil && allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recreate replaces the original role binding by binding, since the roleRef of a role binding cannot be updated. If
+// the new binding cannot be created after deleting the original one, the original one is restored.
+func (r *Replicator) recreate(original *rbacv1.RoleBinding, binding *rbacv1.RoleBinding) (*rbacv1.RoleBinding, error) {
+	logger := log.WithField("kind", r.Kind).WithField("target", common.MustGetKey(binding))
+	logger.Infof("recreating %s to change its roleRef to %s %s", common.MustGetKey(binding), binding.RoleRef.Kind, binding.RoleRef.Name)
+
+	uid := original.UID
+	err := r.Client.RbacV1().RoleBindings(original.Namespace).Delete(original.Name, &metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &uid},
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "Failed deleting %s: %v", common.MustGetKey(original), err)
+	}
+
+	created, err := r.Client.RbacV1().RoleBindings(binding.Namespace).Create(withoutServerFields(binding))
+	if err == nil {
+		return created, nil
+	}
+
+	logger.WithError(err).Warnf("could not recreate %s, restoring its previous roleRef: %v", common.MustGetKey(binding), err)
+	if _, restoreErr := r.Client.RbacV1().RoleBindings(original.Namespace).Create(withoutServerFields(original)); restoreErr != nil {
+		return nil, errors.Wrapf(err, "Failed recreating %s, and failed restoring it (%v)", common.MustGetKey(binding), restoreErr)
+	}
+
+	return nil, errors.Wrapf(err, "Failed recreating %s: %v", common.MustGetKey(binding), err)
+}
+
+// withoutServerFields returns a copy of binding that can be created again after it has been deleted
+func withoutServerFields(binding *rbacv1.RoleBinding) *rbacv1.RoleBinding {
+	binding = binding.DeepCopy()
+	binding.ResourceVersion = ""
+	binding.UID = ""
+	binding.CreationTimestamp = metav1.Time{}
+
+	return binding
+}
+
+// isRoleReplicated checks whether the role referenced by binding is to be replicated along with it
+func isRoleReplicated(binding *rbacv1.RoleBinding) bool {
+	replicate, err := strconv.ParseBool(binding.Annotations[common.ReplicateRoleAnnotation])
+	return err == nil && replicate
+}
+
+// ensureRole makes sure that the role referenced by source exists in namespace. If it does not, it is replicated
+// from the source's namespace if the source has a ReplicateRoleAnnotation; otherwise an error is returned. Roles
+// replicated this way are labeled as replicas of source and kept up-to-date with their source role, while other
+// existing roles are left alone. Bindings to cluster roles are not checked.
+func (r *Replicator) ensureRole(source *rbacv1.RoleBinding, namespace string) error {
+	if source.RoleRef.Kind != "Role" {
+		return nil
+	}
+
+	if !r.roleController.HasSynced() {
+		return errors.Errorf("cache for roles referenced by %s has not been synced yet", r.Kind)
+	}
+
+	roleName := source.RoleRef.Name
+	roleLocation := fmt.Sprintf("%s/%s", namespace, roleName)
+	existing, exists, err := r.roleStore.GetByKey(roleLocation)
+	if err != nil {
+		return errors.Wrapf(err, "Could not get role %s from cache: %v", roleLocation, err)
+	}
+
+	var roleCopy *rbacv1.Role
+	if exists {
+		role := existing.(*rbacv1.Role)
+		if !isRoleReplicated(source) || !r.IsReplicaOf(role, source) {
+			return nil
+		}
+		roleCopy = role.DeepCopy()
+	} else if !isRoleReplicated(source) {
+		return errors.Errorf("role %s referenced by %s does not exist in namespace %s",
+			roleName, common.MustGetKey(source), namespace)
+	} else {
+		roleCopy = &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: roleName, Namespace: namespace}}
+	}
+
+	sourceLocation := fmt.Sprintf("%s/%s", source.Namespace, roleName)
+	sourceObj, sourceExists, err := r.roleStore.GetByKey(sourceLocation)
+	if err != nil {
+		return errors.Wrapf(err, "Could not get role %s from cache: %v", sourceLocation, err)
+	} else if !sourceExists {
+		return errors.Errorf("role %s referenced by %s does not exist", sourceLocation, common.MustGetKey(source))
+	}
+	sourceRole := sourceObj.(*rbacv1.Role)
+
+	if roleCopy.Annotations[common.ReplicatedFromVersionAnnotation] == common.SourceVersion(sourceRole) {
+		return nil
+	}
+
+	if roleCopy.Annotations == nil {
+		roleCopy.Annotations = make(map[string]string)
+	}
+
+	roleCopy.Rules = sourceRole.Rules
+	roleCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	roleCopy.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(sourceRole)
+	r.SetSourceReference(source, roleCopy, !exists)
+
+	log.WithField("kind", r.Kind).WithField("source", common.MustGetKey(source)).
+		Infof("replicating role %s referenced by %s into namespace %s", sourceLocation, common.MustGetKey(source), namespace)
+
+	var obj interface{}
+	if exists {
+		obj, err = r.Client.RbacV1().Roles(namespace).Update(roleCopy)
+	} else {
+		obj, err = r.Client.RbacV1().Roles(namespace).Create(roleCopy)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Failed to replicate role %s into namespace %s: %v", sourceLocation, namespace, err)
+	}
+
+	return r.roleStore.Update(obj)
+}
@@ -0,0 +1,147 @@
+package rolebinding
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func roleBinding(namespace string, roleKind string, roleName string, annotations map[string]string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "binding",
+			Namespace:       namespace,
+			ResourceVersion: "1",
+			Annotations:     annotations,
+		},
+		RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: roleKind, Name: roleName},
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "jane"}},
+	}
+}
+
+func TestRoleRefMismatchRequiresOptIn(t *testing.T) {
+	source := roleBinding("source", "ClusterRole", "edit", map[string]string{})
+	target := roleBinding("target", "ClusterRole", "view", map[string]string{
+		common.ReplicateFromAnnotation: "source/binding",
+	})
+
+	client := fake.NewSimpleClientset(source, target)
+	repl := NewReplicator(client, time.Minute, true, false, 5).(*Replicator)
+
+	require.Error(t, repl.ReplicateDataFrom(source, target))
+
+	target.Annotations[common.RecreateOnRoleRefChangeAnnotation] = "true"
+	require.NoError(t, repl.ReplicateDataFrom(source, target))
+
+	updated, err := client.RbacV1().RoleBindings("target").Get("binding", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, source.RoleRef, updated.RoleRef)
+	require.Equal(t, source.Subjects, updated.Subjects)
+	require.Equal(t, "source/binding", updated.Annotations[common.ReplicateFromAnnotation])
+}
+
+func TestFailedRecreateRestoresTheBinding(t *testing.T) {
+	source := roleBinding("source", "ClusterRole", "edit", map[string]string{})
+	target := roleBinding("target", "ClusterRole", "view", map[string]string{
+		common.ReplicateFromAnnotation:           "source/binding",
+		common.RecreateOnRoleRefChangeAnnotation: "true",
+	})
+
+	client := fake.NewSimpleClientset(source, target)
+	client.PrependReactor("create", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		binding := action.(k8stesting.CreateAction).GetObject().(*rbacv1.RoleBinding)
+		if binding.RoleRef.Name == "edit" {
+			return true, nil, errors.New("admission denied")
+		}
+		return false, nil, nil
+	})
+	repl := NewReplicator(client, time.Minute, true, false, 5).(*Replicator)
+
+	require.Error(t, repl.ReplicateDataFrom(source, target))
+
+	restored, err := client.RbacV1().RoleBindings("target").Get("binding", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, target.RoleRef, restored.RoleRef)
+	require.Equal(t, target.Subjects, restored.Subjects)
+}
+
+// newRoleReplicator creates a replicator whose role cache has been synced, without processing any role bindings
+func newRoleReplicator(t *testing.T, client *fake.Clientset) *Replicator {
+	repl := NewReplicator(client, time.Minute, true, false, 5).(*Replicator)
+	go repl.roleController.Run(wait.NeverStop)
+	require.Eventually(t, repl.roleController.HasSynced, 5*time.Second, 10*time.Millisecond)
+
+	return repl
+}
+
+func TestReferencedRoleIsReplicatedAlong(t *testing.T) {
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "source", ResourceVersion: "1"},
+		Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"*"}}},
+	}
+	source := roleBinding("source", "Role", "deployer", map[string]string{})
+
+	client := fake.NewSimpleClientset(role, source)
+	repl := newRoleReplicator(t, client)
+	namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}}
+
+	require.Error(t, repl.ReplicateObjectTo(source, namespace))
+
+	source.Annotations[common.ReplicateRoleAnnotation] = "true"
+	require.NoError(t, repl.ReplicateObjectTo(source, namespace))
+
+	replicatedRole, err := client.RbacV1().Roles("tenant").Get("deployer", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, role.Rules, replicatedRole.Rules)
+	require.True(t, repl.IsReplicaOf(replicatedRole, source))
+
+	_, err = client.RbacV1().RoleBindings("tenant").Get("binding", metav1.GetOptions{})
+	require.NoError(t, err)
+
+	// changes of the source role are replicated, and the role bindings replicating it are queued for that
+	require.NoError(t, repl.Store.Add(source))
+	role.Rules[0].Verbs = []string{"get"}
+	role.ResourceVersion = "2"
+	_, err = client.RbacV1().Roles("source").Update(role)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return repl.Queue.Len() == 1 }, 5*time.Second, 10*time.Millisecond)
+	key, _ := repl.Queue.Get()
+	require.Equal(t, "source/binding", key)
+
+	require.NoError(t, repl.ReplicateObjectTo(source, namespace))
+	replicatedRole, err = client.RbacV1().Roles("tenant").Get("deployer", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, role.Rules, replicatedRole.Rules)
+}
+
+func TestExistingRolesAreNotReplaced(t *testing.T) {
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "source"},
+		Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"*"}}},
+	}
+	existing := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "tenant"},
+		Rules:      []rbacv1.PolicyRule{{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get"}}},
+	}
+	source := roleBinding("source", "Role", "deployer", map[string]string{common.ReplicateRoleAnnotation: "true"})
+
+	client := fake.NewSimpleClientset(role, existing, source)
+	repl := newRoleReplicator(t, client)
+
+	require.NoError(t, repl.ReplicateObjectTo(source, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}}))
+
+	unchanged, err := client.RbacV1().Roles("tenant").Get("deployer", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, existing, unchanged)
+}
@@ -0,0 +1,149 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Policies for registries present in more than one source when merging docker configs
+const (
+	dockerConfigConflictsLast  = "last"
+	dockerConfigConflictsFirst = "first"
+	dockerConfigConflictsError = "error"
+)
+
+// dockerConfigJSON is the content of the .dockerconfigjson key of a kubernetes.io/dockerconfigjson secret. Entries
+// are kept as raw JSON, so that fields unknown to the replicator (e.g. identitytoken) are preserved.
+type dockerConfigJSON struct {
+	Auths map[string]json.RawMessage `json:"auths"`
+}
+
+// isDockerConfigMergeMode checks whether the docker configs of all sources of target are to be merged
+func isDockerConfigMergeMode(target *v1.Secret) bool {
+	merge, err := strconv.ParseBool(target.Annotations[common.MergeDockerConfigAnnotation])
+	return err == nil && merge
+}
+
+// dockerConfigConflictPolicy returns the policy for registries present in more than one source
+func dockerConfigConflictPolicy(target *v1.Secret) (string, error) {
+	policy, ok := target.Annotations[common.DockerConfigConflictsAnnotation]
+	if !ok {
+		return dockerConfigConflictsLast, nil
+	}
+
+	switch policy {
+	case dockerConfigConflictsLast, dockerConfigConflictsFirst, dockerConfigConflictsError:
+		return policy, nil
+	}
+
+	return "", errors.Errorf("invalid %s annotation '%s': expected one of %s, %s or %s",
+		common.DockerConfigConflictsAnnotation, policy,
+		dockerConfigConflictsLast, dockerConfigConflictsFirst, dockerConfigConflictsError)
+}
+
+// registryHost normalizes a registry given as key of a docker config, so that e.g. "https://index.docker.io/v1/"
+// and "index.docker.io" are recognized as the same registry
+func registryHost(registry string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://")
+	host = strings.TrimSuffix(host, "/")
+	host = strings.TrimSuffix(strings.TrimSuffix(host, "/v1"), "/v2")
+
+	return strings.ToLower(host)
+}
+
+// dockerAuths reads the registry credentials of source, from either its .dockerconfigjson or its legacy .dockercfg
+// key. Keys for which keep returns false are ignored.
+func dockerAuths(source *v1.Secret, keep func(key string) bool) (map[string]json.RawMessage, error) {
+	auths := make(map[string]json.RawMessage)
+
+	if value, ok := source.Data[v1.DockerConfigJsonKey]; ok && keep(v1.DockerConfigJsonKey) {
+		config := dockerConfigJSON{}
+		if err := json.Unmarshal(value, &config); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s in %s", v1.DockerConfigJsonKey, common.MustGetKey(source))
+		}
+		for registry, entry := range config.Auths {
+			auths[registry] = entry
+		}
+	}
+
+	if value, ok := source.Data[v1.DockerConfigKey]; ok && keep(v1.DockerConfigKey) {
+		legacy := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(value, &legacy); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s in %s", v1.DockerConfigKey, common.MustGetKey(source))
+		}
+		for registry, entry := range legacy {
+			if _, ok := auths[registry]; !ok {
+				auths[registry] = entry
+			}
+		}
+	}
+
+	return auths, nil
+}
+
+// mergeDockerConfigs builds a .dockerconfigjson whose auths are the union of the auths of all sources, given in order
+// of increasing precedence. Registries present in more than one source with different credentials are resolved
+// according to policy.
+func mergeDockerConfigs(sources []*v1.Secret, keep []func(key string) bool, policy string) ([]byte, error) {
+	type registryEntry struct {
+		registry string
+		source   string
+		entry    json.RawMessage
+	}
+
+	merged := make(map[string]registryEntry)
+
+	for i, source := range sources {
+		auths, err := dockerAuths(source, keep[i])
+		if err != nil {
+			return nil, err
+		}
+
+		registries := make([]string, 0, len(auths))
+		for registry := range auths {
+			registries = append(registries, registry)
+		}
+		sort.Strings(registries)
+
+		for _, registry := range registries {
+			entry := registryEntry{registry: registry, source: common.MustGetKey(source), entry: auths[registry]}
+			host := registryHost(registry)
+
+			existing, ok := merged[host]
+			if ok && !jsonEqual(existing.entry, entry.entry) {
+				switch policy {
+				case dockerConfigConflictsFirst:
+					continue
+				case dockerConfigConflictsError:
+					return nil, errors.Errorf("registry %s has different credentials in %s and %s",
+						host, existing.source, entry.source)
+				}
+			}
+
+			merged[host] = entry
+		}
+	}
+
+	config := dockerConfigJSON{Auths: make(map[string]json.RawMessage, len(merged))}
+	for _, entry := range merged {
+		config.Auths[entry.registry] = entry.entry
+	}
+
+	return json.Marshal(&config)
+}
+
+func jsonEqual(a json.RawMessage, b json.RawMessage) bool {
+	compactA, compactB := bytes.Buffer{}, bytes.Buffer{}
+	if json.Compact(&compactA, a) != nil || json.Compact(&compactB, b) != nil {
+		return bytes.Equal(a, b)
+	}
+
+	return bytes.Equal(compactA.Bytes(), compactB.Bytes())
+}
@@ -0,0 +1,80 @@
+package secret
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func registrySecret(name string, key string, config string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "registries",
+			ResourceVersion: "1",
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{key: []byte(config)},
+	}
+}
+
+func keepAll(string) bool {
+	return true
+}
+
+func TestMergeDockerConfigsConflictPolicies(t *testing.T) {
+	sources := []*corev1.Secret{
+		registrySecret("hub", corev1.DockerConfigJsonKey, `{"auths":{"https://index.docker.io/v1/":{"auth":"Zmlyc3Q="},"quay.io":{"auth":"cXVheQ=="}}}`),
+		registrySecret("legacy", corev1.DockerConfigKey, `{"index.docker.io":{"auth":"c2Vjb25k"},"quay.io":{"auth": "cXVheQ=="}}`),
+	}
+	keep := []func(string) bool{keepAll, keepAll}
+
+	merged, err := mergeDockerConfigs(sources, keep, dockerConfigConflictsLast)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"auths":{"index.docker.io":{"auth":"c2Vjb25k"},"quay.io":{"auth":"cXVheQ=="}}}`, string(merged))
+
+	merged, err = mergeDockerConfigs(sources, keep, dockerConfigConflictsFirst)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"auths":{"https://index.docker.io/v1/":{"auth":"Zmlyc3Q="},"quay.io":{"auth":"cXVheQ=="}}}`, string(merged))
+
+	// identical credentials for quay.io are no conflict, different ones for Docker Hub are
+	_, err = mergeDockerConfigs(sources, keep, dockerConfigConflictsError)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "index.docker.io")
+}
+
+func TestDockerConfigsOfSeveralSourcesAreMerged(t *testing.T) {
+	hub := registrySecret("hub", corev1.DockerConfigJsonKey, `{"auths":{"index.docker.io":{"auth":"aHVi"}}}`)
+	ghcr := registrySecret("ghcr", corev1.DockerConfigJsonKey, `{"auths":{"ghcr.io":{"auth":"Z2hjcg=="}}}`)
+	target := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pull-secret",
+			Namespace: "app",
+			Annotations: map[string]string{
+				common.ReplicateFromAnnotation:     "registries/hub,registries/ghcr",
+				common.MergeDockerConfigAnnotation: "true",
+			},
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte("{}")},
+	}
+
+	client := fake.NewSimpleClientset(target)
+	repl := NewReplicator(client, time.Minute, true, false, 5, common.MetadataPropagation{}).(*Replicator)
+
+	require.NoError(t, repl.MergeDataFrom([]interface{}{hub, ghcr}, target))
+
+	merged, err := client.CoreV1().Secrets("app").Get("pull-secret", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"auths":{"index.docker.io":{"auth":"aHVi"},"ghcr.io":{"auth":"Z2hjcg=="}}}`,
+		string(merged.Data[corev1.DockerConfigJsonKey]))
+	require.Equal(t, ".dockerconfigjson=merged", merged.Annotations[common.ReplicatedKeySourcesAnnotation])
+
+	merged.Annotations[common.DockerConfigConflictsAnnotation] = "newest"
+	require.Error(t, repl.MergeDataFrom([]interface{}{hub, ghcr}, merged))
+}
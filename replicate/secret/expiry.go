@@ -0,0 +1,148 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/x509"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Roles of TLS secrets used as metric labels
+const (
+	certificateRoleSource  = "source"
+	certificateRoleReplica = "replica"
+)
+
+var certificateExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "replicator",
+	Name:      "certificate_expiry_timestamp_seconds",
+	Help:      "Unix timestamp at which the certificate of a replicated TLS secret, or of the source of a replication, expires",
+}, []string{"namespace", "name", "role"})
+
+func init() {
+	prometheus.MustRegister(certificateExpiry)
+}
+
+// ObjectSynced exports the expiry of the certificate of TLS secrets that are sources or replicas, and records a
+// warning event on replicas whose certificate is older than the one of their source
+func (r *Replicator) ObjectSynced(obj interface{}) {
+	secret := obj.(*v1.Secret)
+	role := r.certificateRole(secret)
+
+	leaf, ok := leafCertificate(secret)
+	if !ok || role == "" {
+		r.ObjectDeleted(obj)
+		return
+	}
+
+	observeCertificateExpiry(secret, leaf, role)
+
+	if role == certificateRoleReplica {
+		r.checkCertificateOutdated(secret, leaf)
+	}
+}
+
+// ObjectDeleted removes the certificate expiry metrics of a deleted secret
+func (r *Replicator) ObjectDeleted(obj interface{}) {
+	secret := obj.(*v1.Secret)
+
+	certificateExpiry.DeleteLabelValues(secret.Namespace, secret.Name, certificateRoleSource)
+	certificateExpiry.DeleteLabelValues(secret.Namespace, secret.Name, certificateRoleReplica)
+
+	r.outdatedLock.Lock()
+	delete(r.outdated, common.MustGetKey(secret))
+	r.outdatedLock.Unlock()
+}
+
+// certificateRole tells whether secret is a replica or a source of replication. It returns an empty string for
+// secrets that are neither.
+func (r *Replicator) certificateRole(secret *v1.Secret) string {
+	key := common.MustGetKey(secret)
+
+	if _, ok := secret.Annotations[common.ReplicateFromAnnotation]; ok {
+		return certificateRoleReplica
+	}
+	if _, ok := secret.Annotations[common.ReplicatedFromVersionAnnotation]; ok {
+		return certificateRoleReplica
+	}
+	if r.ReplicateToList.Has(key) || len(r.Dependencies.Dependents(key)) > 0 {
+		return certificateRoleSource
+	}
+
+	return ""
+}
+
+func observeCertificateExpiry(secret *v1.Secret, leaf *x509.Certificate, role string) {
+	for _, other := range []string{certificateRoleSource, certificateRoleReplica} {
+		if other != role {
+			certificateExpiry.DeleteLabelValues(secret.Namespace, secret.Name, other)
+		}
+	}
+
+	certificateExpiry.WithLabelValues(secret.Namespace, secret.Name, role).Set(float64(leaf.NotAfter.Unix()))
+}
+
+// certificateSources returns the secrets replica is replicated from, in order of increasing precedence
+func (r *Replicator) certificateSources(replica *v1.Secret) []*v1.Secret {
+	sourceKeys := r.Dependencies.Sources(common.MustGetKey(replica))
+	if len(sourceKeys) == 0 {
+		sourceKeys = r.PushSourcesOf(replica)
+	}
+
+	sources := make([]*v1.Secret, 0, len(sourceKeys))
+	for _, sourceKey := range sourceKeys {
+		if r.IsOtherSourceKind(sourceKey) {
+			// sources of another kind do not hold certificates
+			continue
+		}
+
+		source, exists, err := r.Store.GetByKey(sourceKey)
+		if err != nil || !exists {
+			continue
+		}
+		sources = append(sources, source.(*v1.Secret))
+	}
+
+	return sources
+}
+
+// checkCertificateOutdated records a warning event on replica if its certificate differs from, and expires before,
+// the certificate of its source. The event is only recorded once per source certificate.
+func (r *Replicator) checkCertificateOutdated(replica *v1.Secret, leaf *x509.Certificate) {
+	key := common.MustGetKey(replica)
+
+	var source *v1.Secret
+	var sourceLeaf *x509.Certificate
+	for _, candidate := range r.certificateSources(replica) {
+		if candidateLeaf, ok := leafCertificate(candidate); ok {
+			source, sourceLeaf = candidate, candidateLeaf
+			observeCertificateExpiry(candidate, candidateLeaf, certificateRoleSource)
+		}
+	}
+
+	r.outdatedLock.Lock()
+	defer r.outdatedLock.Unlock()
+
+	if sourceLeaf == nil || bytes.Equal(leaf.Raw, sourceLeaf.Raw) || !leaf.NotAfter.Before(sourceLeaf.NotAfter) {
+		delete(r.outdated, key)
+		return
+	}
+
+	fingerprint := sourceLeaf.SerialNumber.String()
+	if r.outdated[key] == fingerprint {
+		return
+	}
+	r.outdated[key] = fingerprint
+
+	log.WithField("kind", r.Kind).WithField("source", common.MustGetKey(source)).WithField("target", key).
+		Warnf("certificate of %s expires at %s, before the certificate of its source %s", key,
+			leaf.NotAfter.UTC().Format(time.RFC3339), common.MustGetKey(source))
+
+	r.Recorder.Eventf(replica, v1.EventTypeWarning, common.ReasonCertificateOutdated,
+		"Certificate expiring at %s is older than the certificate of source %s, which expires at %s",
+		leaf.NotAfter.UTC().Format(time.RFC3339), common.MustGetKey(source), sourceLeaf.NotAfter.UTC().Format(time.RFC3339))
+}
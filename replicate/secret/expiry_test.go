@@ -0,0 +1,82 @@
+package secret
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func tlsSecret(namespace string, certificate *testCertificate, annotations map[string]string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "certificate",
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certificate.certPEM,
+			corev1.TLSPrivateKeyKey: certificate.keyPEM,
+		},
+	}
+}
+
+func TestOutdatedCertificatesOfReplicasAreReported(t *testing.T) {
+	ca := issueCertificate(t, "ca", time.Now().Add(48*time.Hour), nil)
+	current := issueCertificate(t, "current", time.Now().Add(24*time.Hour), ca)
+	previous := issueCertificate(t, "previous", time.Now().Add(time.Hour), ca)
+
+	source := tlsSecret("cert-manager", current, map[string]string{common.ReplicateTo: "tenant"})
+	replica := tlsSecret("tenant", previous, map[string]string{common.ReplicatedFromVersionAnnotation: "1"})
+
+	repl := NewReplicator(fake.NewSimpleClientset(), time.Minute, false, false, 5, common.MetadataPropagation{}).(*Replicator)
+	recorder := record.NewFakeRecorder(10)
+	repl.Recorder = recorder
+
+	require.NoError(t, repl.Store.Add(source))
+	repl.ReplicateToList.Add(common.MustGetKey(source))
+
+	repl.ObjectSynced(source)
+	repl.ObjectSynced(replica)
+	repl.ObjectSynced(replica)
+
+	require.Equal(t, float64(current.certificate.NotAfter.Unix()),
+		testutil.ToFloat64(certificateExpiry.WithLabelValues("cert-manager", "certificate", certificateRoleSource)))
+	require.Equal(t, float64(previous.certificate.NotAfter.Unix()),
+		testutil.ToFloat64(certificateExpiry.WithLabelValues("tenant", "certificate", certificateRoleReplica)))
+
+	require.Len(t, recorder.Events, 1)
+	require.Contains(t, <-recorder.Events, common.ReasonCertificateOutdated)
+
+	upToDate := tlsSecret("tenant", current, replica.Annotations)
+	repl.ObjectSynced(upToDate)
+	require.Len(t, recorder.Events, 0)
+
+	// a replica falling behind again is reported again
+	repl.ObjectSynced(replica)
+	require.Len(t, recorder.Events, 1)
+
+	repl.ObjectDeleted(replica)
+	require.Equal(t, 1, testutil.CollectAndCount(certificateExpiry))
+}
+
+func TestSourcesWithColonsInTheirNameHoldCertificates(t *testing.T) {
+	current := issueCertificate(t, "current", time.Now().Add(24*time.Hour), nil)
+
+	source := tlsSecret("cert-manager", current, nil)
+	source.Name = "tls:wildcard"
+	replica := tlsSecret("tenant", current, map[string]string{common.ReplicateFromAnnotation: "cert-manager/tls:wildcard"})
+
+	repl := NewReplicator(fake.NewSimpleClientset(), time.Minute, false, false, 5, common.MetadataPropagation{}).(*Replicator)
+	require.NoError(t, repl.Store.Add(source))
+	repl.Dependencies.Add("cert-manager/tls:wildcard", "tenant/certificate")
+
+	require.Equal(t, []*corev1.Secret{source}, repl.certificateSources(replica))
+}
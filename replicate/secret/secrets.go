@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mittwald/kubernetes-replicator/replicate/common"
@@ -21,6 +22,11 @@ import (
 
 type Replicator struct {
 	*common.GenericReplicator
+
+	// outdated holds the serial number of the source certificate for all replicas that have been reported to hold an
+	// older certificate
+	outdated     map[string]string
+	outdatedLock sync.Mutex
 }
 
 // NewReplicator creates a new secret replicator
@@ -29,6 +35,8 @@ func NewReplicator(
 	resyncPeriod time.Duration,
 	allowAll bool,
 	strict bool,
+	maxRetries int,
+	propagation common.MetadataPropagation,
 ) common.Replicator {
 	repl := Replicator{
 		GenericReplicator: common.NewGenericReplicator(common.ReplicatorConfig{
@@ -36,21 +44,42 @@ func NewReplicator(
 			ObjType:      &v1.Secret{},
 			AllowAll:     allowAll,
 			Strict:       strict,
+			MaxRetries:   maxRetries,
 			ResyncPeriod: resyncPeriod,
 			Client:       client,
+			Propagation:  propagation,
 			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
 				return client.CoreV1().Secrets("").List(lo)
 			},
 			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
 				return client.CoreV1().Secrets("").Watch(lo)
 			},
+			PatchFunc: func(namespace string, name string, pt types.PatchType, data []byte) (runtime.Object, error) {
+				return client.CoreV1().Secrets(namespace).Patch(name, pt, data)
+			},
+			SourceKinds: map[string]common.SourceKind{
+				"configmap": {
+					ObjType: &v1.ConfigMap{},
+					ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+						return client.CoreV1().ConfigMaps("").List(lo)
+					},
+					WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+						return client.CoreV1().ConfigMaps("").Watch(lo)
+					},
+				},
+			},
 		}),
+		outdated: make(map[string]string),
 	}
 	repl.UpdateFuncs = common.UpdateFuncs{
-		ReplicateDataFrom:        repl.ReplicateDataFrom,
-		ReplicateObjectTo:        repl.ReplicateObjectTo,
-		PatchDeleteDependent:     repl.PatchDeleteDependent,
-		DeleteReplicatedResource: repl.DeleteReplicatedResource,
+		ReplicateDataFrom:         repl.ReplicateDataFrom,
+		ReplicateObjectTo:         repl.ReplicateObjectTo,
+		PatchDeleteDependent:      repl.PatchDeleteDependent,
+		DeleteReplicatedResource:  repl.DeleteReplicatedResource,
+		ReleaseReplicatedResource: repl.ReleaseReplicatedResource,
+		MergeDataFrom:             repl.MergeDataFrom,
+		ObjectSynced:              repl.ObjectSynced,
+		ObjectDeleted:             repl.ObjectDeleted,
 	}
 
 	return &repl
@@ -58,23 +87,108 @@ func NewReplicator(
 
 // ReplicateDataFrom takes a source object and copies over data to target object
 func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interface{}) error {
-	source := sourceObj.(*v1.Secret)
+	return r.MergeDataFrom([]interface{}{sourceObj}, targetObj)
+}
+
+// MergeDataFrom takes several source objects and copies over their data to target object. Keys present in more than
+// one source are taken from the last of them. Sources may be secrets or config maps.
+func (r *Replicator) MergeDataFrom(sourceObjs []interface{}, targetObj interface{}) error {
 	target := targetObj.(*v1.Secret)
+	sources := make([]*v1.Secret, 0, len(sourceObjs))
+	sourceKeys := make([]string, 0, len(sourceObjs))
+	for _, sourceObj := range sourceObjs {
+		switch source := sourceObj.(type) {
+		case *v1.Secret:
+			sources = append(sources, source)
+		case *v1.ConfigMap:
+			sources = append(sources, secretFromConfigMap(source))
+		default:
+			return errors.Errorf("cannot replicate %T into secret %s", sourceObj, common.MustGetKey(target))
+		}
+		sourceKeys = append(sourceKeys, common.MustGetKey(sourceObj))
+	}
 
-	// make sure replication is allowed
 	logger := log.
 		WithField("kind", r.Kind).
-		WithField("source", common.MustGetKey(source)).
+		WithField("source", strings.Join(sourceKeys, ",")).
 		WithField("target", common.MustGetKey(target))
 
-	if ok, err := r.IsReplicationPermitted(&target.ObjectMeta, &source.ObjectMeta); !ok {
-		return errors.Wrapf(err, "replication of target %s is not permitted", common.MustGetKey(source))
+	mapping, err := common.NewKeyMapping(&target.ObjectMeta)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to %s", strings.Join(sourceKeys, ","), common.MustGetKey(target))
 	}
 
+	templates, err := common.NewTemplates(&target.ObjectMeta)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to %s", strings.Join(sourceKeys, ","), common.MustGetKey(target))
+	}
+
+	provenance := common.NewKeyProvenance()
+	inputs := make(map[string]string)
+	names := make([]map[string]string, len(sources))
+	versions := make([]string, len(sources))
+
+	for i, source := range sources {
+		// make sure replication is allowed
+		if ok, err := r.IsReplicationPermitted(&target.ObjectMeta, &source.ObjectMeta); !ok {
+			return errors.Wrapf(err, "replication of target %s is not permitted", common.MustGetKey(source))
+		}
+
+		filter, err := common.NewKeyFilter(&source.ObjectMeta, &target.ObjectMeta)
+		if err != nil {
+			return errors.Wrapf(err, "could not replicate %s to %s", common.MustGetKey(source), common.MustGetKey(target))
+		}
+
+		names[i], err = common.ReplicatedKeyNames(filter, mapping, common.GetKeysFromBinaryMap(source.Data))
+		if err != nil {
+			return errors.Wrapf(err, "could not replicate %s to %s", common.MustGetKey(source), common.MustGetKey(target))
+		}
+
+		provenance.Add(common.MustGetKey(source), names[i])
+		versions[i] = common.SourceVersion(source)
+
+		// templates may use all keys the source permits to be replicated, regardless of the target's filters
+		sourceFilter, err := common.NewKeyFilter(&source.ObjectMeta)
+		if err != nil {
+			return errors.Wrapf(err, "could not replicate %s to %s", common.MustGetKey(source), common.MustGetKey(target))
+		}
+		for key, value := range source.Data {
+			if sourceFilter.Matches(key) {
+				inputs[key] = string(value)
+			}
+		}
+	}
+
+	rendered, err := templates.Render(inputs)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to %s", strings.Join(sourceKeys, ","), common.MustGetKey(target))
+	}
+
+	if _, templated := rendered[v1.DockerConfigJsonKey]; isDockerConfigMergeMode(target) && !templated {
+		merged, err := mergedDockerConfig(sources, names, target)
+		if err != nil {
+			return errors.Wrapf(err, "could not replicate %s to %s", strings.Join(sourceKeys, ","), common.MustGetKey(target))
+		}
+
+		rendered[v1.DockerConfigJsonKey] = string(merged)
+		provenance.AddDerived(common.MergedKeyOrigin, v1.DockerConfigJsonKey)
+	}
+	if _, templated := rendered[v1.TLSCertKey]; isTLSBundleMode(target) && !templated {
+		bundle, err := bundledCertificate(sources, names)
+		if err != nil {
+			return errors.Wrapf(err, "could not replicate %s to %s", strings.Join(sourceKeys, ","), common.MustGetKey(target))
+		}
+
+		rendered[v1.TLSCertKey] = string(bundle)
+		provenance.AddDerived(common.BundledKeyOrigin, v1.TLSCertKey)
+	}
+	provenance.AddDerived(common.RenderedKeyOrigin, templates.Keys()...)
+
 	targetVersion, ok := target.Annotations[common.ReplicatedFromVersionAnnotation]
-	sourceVersion := source.ResourceVersion
+	sourceVersion := strings.Join(versions, ",")
 
-	if ok && targetVersion == sourceVersion && !r.Strict {
+	if ok && targetVersion == sourceVersion && !r.Strict && provenance.UpToDate(&target.ObjectMeta) && renderedUpToDate(target, rendered) &&
+		certificateNotAfterUpToDate(target) {
 		logger.Debugf("target %s is already up-to-date", common.MustGetKey(target))
 		return nil
 	}
@@ -86,31 +200,46 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 	}
 
 	prevKeys, hasPrevKeys := common.PreviouslyPresentKeys(&targetCopy.ObjectMeta)
-	replicatedKeys := make([]string, 0)
 
-	for key, value := range source.Data {
-		newValue := make([]byte, len(value))
-		copy(newValue, value)
-		targetCopy.Data[key] = newValue
+	for i, source := range sources {
+		for key, value := range source.Data {
+			name, ok := names[i][key]
+			if !ok {
+				continue
+			}
+
+			newValue := make([]byte, len(value))
+			copy(newValue, value)
+			targetCopy.Data[name] = newValue
 
-		replicatedKeys = append(replicatedKeys, key)
+			delete(prevKeys, name)
+		}
+	}
+
+	for key, value := range rendered {
+		targetCopy.Data[key] = []byte(value)
 		delete(prevKeys, key)
 	}
 
 	if hasPrevKeys {
 		for k := range prevKeys {
-			logger.Debugf("removing previously present key %s: not present in source any more", k)
+			logger.Debugf("removing previously present key %s: not present in any source or excluded from replication", k)
 			delete(targetCopy.Data, k)
 		}
 	}
 
-	sort.Strings(replicatedKeys)
+	if isTLSBundleMode(target) {
+		if err := validateKeyPair(targetCopy.Data); err != nil {
+			return errors.Wrapf(err, "refusing to replicate %s to %s", strings.Join(sourceKeys, ","), common.MustGetKey(target))
+		}
+	}
 
 	logger.Infof("updating target %s", common.MustGetKey(target))
 
 	targetCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
-	targetCopy.Annotations[common.ReplicatedKeysAnnotation] = strings.Join(replicatedKeys, ",")
+	targetCopy.Annotations[common.ReplicatedFromVersionAnnotation] = sourceVersion
+	provenance.Apply(targetCopy.Annotations)
+	setCertificateNotAfter(targetCopy)
 
 	s, err := r.Client.CoreV1().Secrets(target.Namespace).Update(targetCopy)
 	if err != nil {
@@ -121,10 +250,95 @@ func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interfac
 	return err
 }
 
+// mergedDockerConfig merges the registry credentials of all sources into a single .dockerconfigjson. Only keys
+// selected for replication into target (as given by names) are taken into account.
+func mergedDockerConfig(sources []*v1.Secret, names []map[string]string, target *v1.Secret) ([]byte, error) {
+	policy, err := dockerConfigConflictPolicy(target)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make([]func(key string) bool, len(sources))
+	for i := range sources {
+		sourceNames := names[i]
+		keep[i] = func(key string) bool {
+			_, ok := sourceNames[key]
+			return ok
+		}
+	}
+
+	return mergeDockerConfigs(sources, keep, policy)
+}
+
+// bundledCertificate builds the tls.crt of a target in TLS bundle mode from the tls.crt and ca.crt replicated from
+// its sources
+func bundledCertificate(sources []*v1.Secret, names []map[string]string) ([]byte, error) {
+	leaf, ok := replicatedValue(sources, names, v1.TLSCertKey)
+	if !ok {
+		return nil, errors.Errorf("none of the sources provides %s", v1.TLSCertKey)
+	}
+
+	ca, ok := replicatedValue(sources, names, tlsCAKey)
+	if !ok {
+		return nil, errors.Errorf("none of the sources provides %s", tlsCAKey)
+	}
+
+	return bundleCertificates(leaf, ca)
+}
+
+// replicatedValue returns the value that is replicated into the target key name, taken from the last source
+// providing it
+func replicatedValue(sources []*v1.Secret, names []map[string]string, name string) ([]byte, bool) {
+	var value []byte
+	found := false
+
+	for i, source := range sources {
+		for key, sourceValue := range source.Data {
+			if names[i][key] == name {
+				value, found = sourceValue, true
+			}
+		}
+	}
+
+	return value, found
+}
+
+// secretFromConfigMap converts configMap into a secret holding both its data and binary data
+func secretFromConfigMap(configMap *v1.ConfigMap) *v1.Secret {
+	converted := v1.Secret{
+		ObjectMeta: *configMap.ObjectMeta.DeepCopy(),
+		Data:       make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData)),
+	}
+
+	for key, value := range configMap.Data {
+		converted.Data[key] = []byte(value)
+	}
+	for key, value := range configMap.BinaryData {
+		converted.Data[key] = append([]byte(nil), value...)
+	}
+
+	return &converted
+}
+
+// renderedUpToDate checks whether target contains the rendered values
+func renderedUpToDate(target *v1.Secret, rendered map[string]string) bool {
+	for key, value := range rendered {
+		if current, ok := target.Data[key]; !ok || string(current) != value {
+			return false
+		}
+	}
+
+	return true
+}
+
 // ReplicateObjectTo copies the whole object to target namespace
 func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
 	source := sourceObj.(*v1.Secret)
-	targetLocation := fmt.Sprintf("%s/%s", target.Name, source.Name)
+	targetName, err := common.TargetName(source, target.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to namespace %s", common.MustGetKey(source), target.Name)
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
 
 	logger := log.
 		WithField("kind", r.Kind).
@@ -138,13 +352,30 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 	}
 	logger.Infof("Checking if %s exists? %v", targetLocation, exists)
 
+	var targetMeta *metav1.ObjectMeta
+	if exists {
+		targetMeta = &targetResource.(*v1.Secret).ObjectMeta
+	}
+
+	filter, err := common.NewKeyFilter(&source.ObjectMeta, targetMeta)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to %s", common.MustGetKey(source), targetLocation)
+	}
+
+	names, err := common.ReplicatedKeyNames(filter, nil, common.GetKeysFromBinaryMap(source.Data))
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to %s", common.MustGetKey(source), targetLocation)
+	}
+
 	var resourceCopy *v1.Secret
 	if exists {
 		targetObject := targetResource.(*v1.Secret)
 		targetVersion, ok := targetObject.Annotations[common.ReplicatedFromVersionAnnotation]
-		sourceVersion := source.ResourceVersion
+		sourceVersion := common.SourceVersion(source)
 
-		if ok && targetVersion == sourceVersion {
+		if ok && targetVersion == sourceVersion && r.IsReplicaOf(targetObject, source) &&
+			common.ReplicatedKeysUpToDate(&targetObject.ObjectMeta, names) && certificateNotAfterUpToDate(targetObject) &&
+			r.Propagation.UpToDate(&source.ObjectMeta, &targetObject.ObjectMeta) {
 			logger.Debugf("Secret %s is already up-to-date", common.MustGetKey(targetObject))
 			return nil
 		}
@@ -162,14 +393,17 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 		resourceCopy.Annotations = make(map[string]string)
 	}
 
-	replicatedKeys := r.extractReplicatedKeys(source, targetLocation, resourceCopy)
+	replicatedKeys := r.extractReplicatedKeys(source, targetLocation, resourceCopy, names)
 
 	sort.Strings(replicatedKeys)
-	resourceCopy.Name = source.Name
+	resourceCopy.Name = targetName
 	resourceCopy.Type = targetResourceType
 	resourceCopy.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
-	resourceCopy.Annotations[common.ReplicatedFromVersionAnnotation] = source.ResourceVersion
+	resourceCopy.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
 	resourceCopy.Annotations[common.ReplicatedKeysAnnotation] = strings.Join(replicatedKeys, ",")
+	setCertificateNotAfter(resourceCopy)
+	r.Propagation.Apply(&source.ObjectMeta, &resourceCopy.ObjectMeta)
+	r.SetSourceReference(source, resourceCopy, !exists)
 
 	var obj interface{}
 	if exists {
@@ -188,7 +422,9 @@ func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespa
 	return err
 }
 
-func (r *Replicator) extractReplicatedKeys(source *v1.Secret, targetLocation string, resourceCopy *v1.Secret) []string {
+// extractReplicatedKeys copies the keys of source listed in names into resourceCopy, stored under their mapped name,
+// and removes previously replicated keys that are not replicated any more
+func (r *Replicator) extractReplicatedKeys(source *v1.Secret, targetLocation string, resourceCopy *v1.Secret, names map[string]string) []string {
 	logger := log.
 		WithField("kind", r.Kind).
 		WithField("source", common.MustGetKey(source)).
@@ -198,17 +434,22 @@ func (r *Replicator) extractReplicatedKeys(source *v1.Secret, targetLocation str
 	replicatedKeys := make([]string, 0)
 
 	for key, value := range source.Data {
+		name, ok := names[key]
+		if !ok {
+			continue
+		}
+
 		newValue := make([]byte, len(value))
 		copy(newValue, value)
-		resourceCopy.Data[key] = newValue
+		resourceCopy.Data[name] = newValue
 
-		replicatedKeys = append(replicatedKeys, key)
-		delete(prevKeys, key)
+		replicatedKeys = append(replicatedKeys, name)
+		delete(prevKeys, name)
 	}
 
 	if hasPrevKeys {
 		for k := range prevKeys {
-			logger.Debugf("removing previously present key %s: not present in source secret any more", k)
+			logger.Debugf("removing previously present key %s: not present in source secret or excluded from replication", k)
 			delete(resourceCopy.Data, k)
 		}
 	}
@@ -267,3 +508,27 @@ func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error
 
 	return nil
 }
+
+// ReleaseReplicatedResource removes the replicated keys and the source reference from a secret that already existed
+// when it was first replicated into
+func (r *Replicator) ReleaseReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	object := targetResource.(*v1.Secret)
+
+	released := object.DeepCopy()
+	if keys, ok := common.PreviouslyPresentKeys(&released.ObjectMeta); ok {
+		for k := range keys {
+			delete(released.Data, k)
+		}
+	}
+	delete(released.Annotations, common.ReplicatedKeysAnnotation)
+	common.RemoveSourceReference(released)
+
+	log.WithField("kind", r.Kind).WithField("target", targetLocation).Debugf("Releasing %s", targetLocation)
+	s, err := r.Client.CoreV1().Secrets(object.Namespace).Update(released)
+	if err != nil {
+		return errors.Wrapf(err, "Failed updating %s: %v", targetLocation, err)
+	}
+
+	return r.Store.Update(s)
+}
@@ -70,7 +70,7 @@ func TestSecretReplicator(t *testing.T) {
 	prefix := namespacePrefix()
 	client := kubernetes.NewForConfigOrDie(config)
 
-	repl := NewReplicator(client, 60*time.Second, false, false)
+	repl := NewReplicator(client, 60*time.Second, false, false, 5, common.MetadataPropagation{})
 	go repl.Run()
 
 	time.Sleep(200 * time.Millisecond)
@@ -355,6 +355,72 @@ func TestSecretReplicator(t *testing.T) {
 		require.False(t, hasFoo)
 	})
 
+	t.Run("replication honours key filters", func(t *testing.T) {
+		source := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "source-key-filter",
+				Namespace: ns.Name,
+				Annotations: map[string]string{
+					common.ReplicationAllowed:           "true",
+					common.ReplicationAllowedNamespaces: ns.Name,
+					common.ReplicateKeysAnnotation:      "*.crt",
+				},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{
+				"ca.crt":  []byte("Hello CA"),
+				"tls.crt": []byte("Hello Cert"),
+				"tls.key": []byte("Hello Key"),
+			},
+		}
+
+		target := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "target-key-filter",
+				Namespace: ns.Name,
+				Annotations: map[string]string{
+					common.ReplicateFromAnnotation: common.MustGetKey(&source),
+					common.ExcludeKeysAnnotation:   "tls.*",
+				},
+			},
+			Type: corev1.SecretTypeOpaque,
+		}
+
+		wg, stop := waitForSecrets(client, 3, EventHandlerFuncs{
+			AddFunc: func(wg *sync.WaitGroup, obj interface{}) {
+				secret := obj.(*corev1.Secret)
+				if secret.Namespace == source.Namespace && secret.Name == source.Name {
+					log.Debugf("AddFunc %+v", obj)
+					wg.Done()
+				} else if secret.Namespace == target.Namespace && secret.Name == target.Name {
+					log.Debugf("AddFunc %+v", obj)
+					wg.Done()
+				}
+			},
+			UpdateFunc: func(wg *sync.WaitGroup, oldObj interface{}, newObj interface{}) {
+				secret := oldObj.(*corev1.Secret)
+				if secret.Namespace == target.Namespace && secret.Name == target.Name {
+					log.Debugf("UpdateFunc %+v -> %+v", oldObj, newObj)
+					wg.Done()
+				}
+			},
+		})
+
+		_, err := secrets.Create(&source)
+		require.NoError(t, err)
+
+		_, err = secrets.Create(&target)
+		require.NoError(t, err)
+
+		waitWithTimeout(wg, MaxWaitTime)
+		close(stop)
+
+		updTarget, err := secrets.Get(target.Name, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, map[string][]byte{"ca.crt": []byte("Hello CA")}, updTarget.Data)
+		require.Equal(t, "ca.crt", updTarget.Annotations[common.ReplicatedKeysAnnotation])
+	})
+
 	t.Run("replication does not remove original values", func(t *testing.T) {
 		source := corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
@@ -881,7 +947,7 @@ func TestSecretReplicatorStrict(t *testing.T) {
 	prefix := namespacePrefix()
 	client := kubernetes.NewForConfigOrDie(config)
 
-	repl := NewReplicator(client, 60*time.Second, false, true)
+	repl := NewReplicator(client, 60*time.Second, false, true, 5, common.MetadataPropagation{})
 	go repl.Run()
 
 	time.Sleep(200 * time.Millisecond)
@@ -0,0 +1,137 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"strconv"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// tlsCAKey is the key holding the CA certificates that are appended to the leaf certificate in TLS bundle mode
+const tlsCAKey = "ca.crt"
+
+// isTLSBundleMode checks whether the tls.crt of target is to be assembled from the leaf certificate and the CA of its
+// sources
+func isTLSBundleMode(target *v1.Secret) bool {
+	bundle, err := strconv.ParseBool(target.Annotations[common.TLSBundleAnnotation])
+	return err == nil && bundle
+}
+
+// parseCertificates parses all certificates of a PEM encoded chain. Blocks other than certificates are ignored.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	certificates := make([]*x509.Certificate, 0)
+
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	if len(certificates) == 0 {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+
+	return certificates, nil
+}
+
+// bundleCertificates builds a full chain from the leaf certificate (which may already be followed by intermediates)
+// and the CA certificates. Certificates already contained in leaf are not appended again.
+func bundleCertificates(leaf []byte, ca []byte) ([]byte, error) {
+	chain, err := parseCertificates(leaf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", v1.TLSCertKey)
+	}
+
+	authorities, err := parseCertificates(ca)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", tlsCAKey)
+	}
+
+	for _, authority := range authorities {
+		contained := false
+		for _, certificate := range chain {
+			contained = contained || bytes.Equal(certificate.Raw, authority.Raw)
+		}
+		if !contained {
+			chain = append(chain, authority)
+		}
+	}
+
+	bundle := bytes.Buffer{}
+	for _, certificate := range chain {
+		if err := pem.Encode(&bundle, &pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}); err != nil {
+			return nil, err
+		}
+	}
+
+	return bundle.Bytes(), nil
+}
+
+// validateKeyPair makes sure that data holds a certificate and a matching private key
+func validateKeyPair(data map[string][]byte) error {
+	if _, err := parseCertificates(data[v1.TLSCertKey]); err != nil {
+		return errors.Wrapf(err, "invalid %s", v1.TLSCertKey)
+	}
+
+	if _, err := tls.X509KeyPair(data[v1.TLSCertKey], data[v1.TLSPrivateKeyKey]); err != nil {
+		return errors.Wrapf(err, "%s does not match %s", v1.TLSPrivateKeyKey, v1.TLSCertKey)
+	}
+
+	return nil
+}
+
+// certificateNotAfter returns the expiry of the leaf certificate of a kubernetes.io/tls secret, formatted as
+// RFC3339. ok is false for other secrets and for secrets without a valid certificate.
+func certificateNotAfter(secret *v1.Secret) (notAfter string, ok bool) {
+	leaf, ok := leafCertificate(secret)
+	if !ok {
+		return "", false
+	}
+
+	return leaf.NotAfter.UTC().Format(time.RFC3339), true
+}
+
+// leafCertificate returns the first certificate of the tls.crt of a kubernetes.io/tls secret. ok is false for other
+// secrets and for secrets without a valid certificate.
+func leafCertificate(secret *v1.Secret) (leaf *x509.Certificate, ok bool) {
+	if secret.Type != v1.SecretTypeTLS {
+		return nil, false
+	}
+
+	certificates, err := parseCertificates(secret.Data[v1.TLSCertKey])
+	if err != nil {
+		return nil, false
+	}
+
+	return certificates[0], true
+}
+
+// certificateNotAfterUpToDate checks whether the CertificateNotAfterAnnotation of secret matches its certificate
+func certificateNotAfterUpToDate(secret *v1.Secret) bool {
+	notAfter, ok := certificateNotAfter(secret)
+	current, annotated := secret.Annotations[common.CertificateNotAfterAnnotation]
+
+	return ok == annotated && notAfter == current
+}
+
+// setCertificateNotAfter annotates secret with the expiry of its certificate, or removes the annotation if it does
+// not hold a valid certificate
+func setCertificateNotAfter(secret *v1.Secret) {
+	if notAfter, ok := certificateNotAfter(secret); ok {
+		secret.Annotations[common.CertificateNotAfterAnnotation] = notAfter
+	} else {
+		delete(secret.Annotations, common.CertificateNotAfterAnnotation)
+	}
+}
@@ -0,0 +1,128 @@
+package secret
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	certPEM     []byte
+	keyPEM      []byte
+}
+
+func issueCertificate(t *testing.T, name string, notAfter time.Time, issuer *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  issuer == nil,
+		BasicConstraintsValid: true,
+	}
+
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.certificate, issuer.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCertificate{
+		certificate: certificate,
+		key:         key,
+		certPEM:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func TestBundleCertificatesAppendsCAOnce(t *testing.T) {
+	ca := issueCertificate(t, "ca", time.Now().Add(24*time.Hour), nil)
+	leaf := issueCertificate(t, "leaf", time.Now().Add(time.Hour), ca)
+
+	bundle, err := bundleCertificates(leaf.certPEM, ca.certPEM)
+	require.NoError(t, err)
+	require.Equal(t, string(leaf.certPEM)+string(ca.certPEM), string(bundle))
+
+	bundle, err = bundleCertificates(bundle, ca.certPEM)
+	require.NoError(t, err)
+	require.Equal(t, string(leaf.certPEM)+string(ca.certPEM), string(bundle))
+
+	_, err = bundleCertificates(leaf.certPEM, []byte("not a certificate"))
+	require.Error(t, err)
+}
+
+func TestTLSBundleIsAssembledFromSeveralSources(t *testing.T) {
+	ca := issueCertificate(t, "ca", time.Now().Add(24*time.Hour), nil)
+	leaf := issueCertificate(t, "leaf", time.Now().Add(time.Hour), ca)
+	other := issueCertificate(t, "other", time.Now().Add(time.Hour), ca)
+
+	leafSource := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "leaf", Namespace: "certs", ResourceVersion: "1"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       leaf.certPEM,
+			corev1.TLSPrivateKeyKey: leaf.keyPEM,
+		},
+	}
+	caSource := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "certs", ResourceVersion: "2"},
+		Data:       map[string]string{tlsCAKey: string(ca.certPEM)},
+	}
+	target := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tls",
+			Namespace: "app",
+			Annotations: map[string]string{
+				common.ReplicateFromAnnotation: "certs/leaf,configmap:certs/ca",
+				common.TLSBundleAnnotation:     "true",
+			},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{corev1.TLSCertKey: nil, corev1.TLSPrivateKeyKey: nil},
+	}
+
+	client := fake.NewSimpleClientset(target)
+	repl := NewReplicator(client, time.Minute, true, false, 5, common.MetadataPropagation{}).(*Replicator)
+
+	require.NoError(t, repl.MergeDataFrom([]interface{}{leafSource, caSource}, target))
+
+	bundled, err := client.CoreV1().Secrets("app").Get("tls", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, string(leaf.certPEM)+string(ca.certPEM), string(bundled.Data[corev1.TLSCertKey]))
+	require.Equal(t, leaf.certificate.NotAfter.UTC().Format(time.RFC3339),
+		bundled.Annotations[common.CertificateNotAfterAnnotation])
+
+	mismatched := leafSource.DeepCopy()
+	mismatched.ResourceVersion = "3"
+	mismatched.Data[corev1.TLSPrivateKeyKey] = other.keyPEM
+
+	err = repl.MergeDataFrom([]interface{}{mismatched, caSource}, bundled)
+	require.Error(t, err)
+
+	unchanged, err := client.CoreV1().Secrets("app").Get("tls", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, string(leaf.keyPEM), string(unchanged.Data[corev1.TLSPrivateKeyKey]))
+}
@@ -0,0 +1,315 @@
+package serviceaccount
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Replicator struct {
+	*common.GenericReplicator
+}
+
+// NewReplicator creates a new service account replicator
+func NewReplicator(
+	client kubernetes.Interface,
+	resyncPeriod time.Duration,
+	allowAll bool,
+	strict bool,
+	maxRetries int,
+) common.Replicator {
+	repl := Replicator{
+		GenericReplicator: common.NewGenericReplicator(common.ReplicatorConfig{
+			Kind:         "ServiceAccount",
+			ObjType:      &v1.ServiceAccount{},
+			AllowAll:     allowAll,
+			Strict:       strict,
+			MaxRetries:   maxRetries,
+			ResyncPeriod: resyncPeriod,
+			Client:       client,
+			ListFunc: func(lo metav1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().ServiceAccounts("").List(lo)
+			},
+			WatchFunc: func(lo metav1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().ServiceAccounts("").Watch(lo)
+			},
+			PatchFunc: func(namespace string, name string, pt types.PatchType, data []byte) (runtime.Object, error) {
+				return client.CoreV1().ServiceAccounts(namespace).Patch(name, pt, data)
+			},
+		}),
+	}
+	repl.UpdateFuncs = common.UpdateFuncs{
+		ReplicateDataFrom:         repl.ReplicateDataFrom,
+		ReplicateObjectTo:         repl.ReplicateObjectTo,
+		PatchDeleteDependent:      repl.PatchDeleteDependent,
+		DeleteReplicatedResource:  repl.DeleteReplicatedResource,
+		ReleaseReplicatedResource: repl.ReleaseReplicatedResource,
+	}
+
+	return &repl
+}
+
+// ReplicateDataFrom takes a source object and copies over image pull secrets to target object
+func (r *Replicator) ReplicateDataFrom(sourceObj interface{}, targetObj interface{}) error {
+	source := sourceObj.(*v1.ServiceAccount)
+	target := targetObj.(*v1.ServiceAccount)
+
+	logger := log.
+		WithField("kind", r.Kind).
+		WithField("source", common.MustGetKey(source)).
+		WithField("target", common.MustGetKey(target))
+
+	// make sure replication is allowed
+	if ok, err := r.IsReplicationPermitted(&target.ObjectMeta, &source.ObjectMeta); !ok {
+		return errors.Wrapf(err, "replication of target %s is not permitted", common.MustGetKey(source))
+	}
+
+	targetVersion, ok := target.Annotations[common.ReplicatedFromVersionAnnotation]
+	sourceVersion := common.SourceVersion(source)
+
+	if ok && targetVersion == sourceVersion && !r.Strict {
+		logger.Debugf("target %s is already up-to-date", common.MustGetKey(target))
+		return nil
+	}
+
+	targetCopy := target.DeepCopy()
+	if targetCopy.Annotations == nil {
+		targetCopy.Annotations = make(map[string]string)
+	}
+
+	copyServiceAccount(source, targetCopy, isMergeMode(source, target))
+
+	logger.Infof("updating target %s/%s", target.Namespace, target.Name)
+
+	s, err := r.Client.CoreV1().ServiceAccounts(target.Namespace).Update(targetCopy)
+	if err != nil {
+		err = errors.Wrapf(err, "Failed updating target %s/%s", target.Namespace, targetCopy.Name)
+	} else if err = r.Store.Update(s); err != nil {
+		err = errors.Wrapf(err, "Failed to update cache for %s/%s: %v", target.Namespace, targetCopy.Name, err)
+	}
+
+	return err
+}
+
+// ReplicateObjectTo copies the whole object to target namespace. In merge mode, image pull secrets are added to an
+// already existing service account.
+func (r *Replicator) ReplicateObjectTo(sourceObj interface{}, target *v1.Namespace) error {
+	source := sourceObj.(*v1.ServiceAccount)
+	targetName, err := common.TargetName(source, target.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not replicate %s to namespace %s", common.MustGetKey(source), target.Name)
+	}
+	targetLocation := fmt.Sprintf("%s/%s", target.Name, targetName)
+
+	logger := log.
+		WithField("kind", r.Kind).
+		WithField("source", common.MustGetKey(source)).
+		WithField("target", targetLocation)
+
+	targetResource, exists, err := r.Store.GetByKey(targetLocation)
+	if err != nil {
+		return errors.Wrapf(err, "Could not get %s from cache!", targetLocation)
+	}
+	logger.Infof("Checking if %s exists? %v", targetLocation, exists)
+
+	var targetCopy *v1.ServiceAccount
+	merge := isMergeMode(source, nil)
+	if exists {
+		targetObject := targetResource.(*v1.ServiceAccount)
+		merge = isMergeMode(source, targetObject)
+		targetVersion, ok := targetObject.Annotations[common.ReplicatedFromVersionAnnotation]
+		sourceVersion := common.SourceVersion(source)
+
+		if ok && targetVersion == sourceVersion && r.IsReplicaOf(targetObject, source) {
+			logger.Debugf("ServiceAccount %s is already up-to-date", common.MustGetKey(targetObject))
+			return nil
+		}
+
+		targetCopy = targetObject.DeepCopy()
+	} else {
+		targetCopy = new(v1.ServiceAccount)
+	}
+
+	if targetCopy.Annotations == nil {
+		targetCopy.Annotations = make(map[string]string)
+	}
+
+	targetCopy.Name = targetName
+	copyServiceAccount(source, targetCopy, merge)
+	r.SetSourceReference(source, targetCopy, !exists)
+
+	var obj interface{}
+	if exists {
+		logger.Debugf("Updating existing service account %s/%s", target.Name, targetCopy.Name)
+		obj, err = r.Client.CoreV1().ServiceAccounts(target.Name).Update(targetCopy)
+	} else {
+		logger.Debugf("Creating a new service account %s/%s", target.Name, targetCopy.Name)
+		obj, err = r.Client.CoreV1().ServiceAccounts(target.Name).Create(targetCopy)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Failed to update service account %s/%s", target.Name, targetCopy.Name)
+	}
+
+	if err := r.Store.Update(obj); err != nil {
+		return errors.Wrapf(err, "Failed to update cache for %s/%s", target.Name, targetCopy.Name)
+	}
+
+	return nil
+}
+
+// PatchDeleteDependent removes the replicated image pull secrets from a target whose source has been deleted
+func (r *Replicator) PatchDeleteDependent(sourceKey string, target interface{}) (interface{}, error) {
+	dependentKey := common.MustGetKey(target)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"source": sourceKey,
+		"target": dependentKey,
+	})
+
+	targetObject, ok := target.(*v1.ServiceAccount)
+	if !ok {
+		err := errors.Errorf("bad type returned from Store: %T", target)
+		return nil, err
+	}
+
+	logger.Debugf("clearing dependent service account %s", dependentKey)
+
+	s, err := r.Client.CoreV1().ServiceAccounts(targetObject.Namespace).Update(withoutReplicatedPullSecrets(targetObject))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while updating service account %s: %v", dependentKey, err)
+	}
+
+	return s, nil
+}
+
+// DeleteReplicatedResource deletes a resource replicated by ReplicateTo annotation. Service accounts that were not
+// created by the replicator, like the default service account of a namespace, are not deleted regardless of whether
+// image pull secrets have been merged into them; they are only released.
+func (r *Replicator) DeleteReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	logger := log.WithFields(log.Fields{
+		"kind":   r.Kind,
+		"target": targetLocation,
+	})
+
+	object := targetResource.(*v1.ServiceAccount)
+
+	if created, _ := strconv.ParseBool(object.Annotations[common.CreatedReplicaAnnotation]); !created {
+		return r.ReleaseReplicatedResource(targetResource)
+	}
+
+	logger.Debugf("Deleting %s", targetLocation)
+	if err := r.Client.CoreV1().ServiceAccounts(object.Namespace).Delete(object.Name, &metav1.DeleteOptions{}); err != nil {
+		return errors.Wrapf(err, "Failed deleting %s: %v", targetLocation, err)
+	}
+
+	return nil
+}
+
+// ReleaseReplicatedResource removes the replicated image pull secrets and the source reference from a service account
+// that already existed when it was first replicated into
+func (r *Replicator) ReleaseReplicatedResource(targetResource interface{}) error {
+	targetLocation := common.MustGetKey(targetResource)
+	object := targetResource.(*v1.ServiceAccount)
+
+	released := withoutReplicatedPullSecrets(object)
+	common.RemoveSourceReference(released)
+
+	log.WithField("kind", r.Kind).WithField("target", targetLocation).Debugf("Releasing %s", targetLocation)
+	s, err := r.Client.CoreV1().ServiceAccounts(object.Namespace).Update(released)
+	if err != nil {
+		return errors.Wrapf(err, "Failed updating %s: %v", targetLocation, err)
+	}
+
+	return r.Store.Update(s)
+}
+
+// isMergeMode checks whether the source or the target (if any) request image pull secrets to be merged
+func isMergeMode(source *v1.ServiceAccount, target *v1.ServiceAccount) bool {
+	if merge, err := strconv.ParseBool(source.Annotations[common.MergeImagePullSecretsAnnotation]); err == nil && merge {
+		return true
+	}
+
+	if target == nil {
+		return false
+	}
+
+	merge, err := strconv.ParseBool(target.Annotations[common.MergeImagePullSecretsAnnotation])
+	return err == nil && merge
+}
+
+// copyServiceAccount copies the image pull secrets and the token automount setting of source into target. In merge
+// mode, the image pull secrets of source are added to the ones already present in target; the names of the added
+// entries are recorded in the ReplicatedKeysAnnotation so that they can be removed again later.
+func copyServiceAccount(source *v1.ServiceAccount, target *v1.ServiceAccount, merge bool) {
+	if merge {
+		previous, _ := common.PreviouslyPresentKeys(&target.ObjectMeta)
+		pullSecrets := make([]v1.LocalObjectReference, 0)
+		present := make(map[string]struct{})
+
+		// keep all entries that were not added by an earlier replication
+		for _, ref := range target.ImagePullSecrets {
+			if _, replicated := previous[ref.Name]; !replicated {
+				pullSecrets = append(pullSecrets, ref)
+				present[ref.Name] = struct{}{}
+			}
+		}
+
+		replicatedKeys := make([]string, 0)
+		for _, ref := range source.ImagePullSecrets {
+			if _, ok := present[ref.Name]; ok {
+				continue
+			}
+			pullSecrets = append(pullSecrets, ref)
+			present[ref.Name] = struct{}{}
+			replicatedKeys = append(replicatedKeys, ref.Name)
+		}
+
+		sort.Strings(replicatedKeys)
+		target.ImagePullSecrets = pullSecrets
+		target.Annotations[common.ReplicatedKeysAnnotation] = strings.Join(replicatedKeys, ",")
+	} else {
+		target.ImagePullSecrets = append([]v1.LocalObjectReference(nil), source.ImagePullSecrets...)
+		target.AutomountServiceAccountToken = source.AutomountServiceAccountToken
+		delete(target.Annotations, common.ReplicatedKeysAnnotation)
+	}
+
+	target.Annotations[common.ReplicatedAtAnnotation] = time.Now().Format(time.RFC3339)
+	target.Annotations[common.ReplicatedFromVersionAnnotation] = common.SourceVersion(source)
+}
+
+// withoutReplicatedPullSecrets returns a copy of target without the image pull secrets added by replication. If the
+// image pull secrets were not merged, all of them are removed.
+func withoutReplicatedPullSecrets(target *v1.ServiceAccount) *v1.ServiceAccount {
+	targetCopy := target.DeepCopy()
+
+	replicated, merged := common.PreviouslyPresentKeys(&targetCopy.ObjectMeta)
+	if !merged {
+		targetCopy.ImagePullSecrets = nil
+		return targetCopy
+	}
+
+	pullSecrets := make([]v1.LocalObjectReference, 0)
+	for _, ref := range targetCopy.ImagePullSecrets {
+		if _, ok := replicated[ref.Name]; !ok {
+			pullSecrets = append(pullSecrets, ref)
+		}
+	}
+
+	targetCopy.ImagePullSecrets = pullSecrets
+	delete(targetCopy.Annotations, common.ReplicatedKeysAnnotation)
+
+	return targetCopy
+}
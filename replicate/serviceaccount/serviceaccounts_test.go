@@ -0,0 +1,148 @@
+package serviceaccount
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mittwald/kubernetes-replicator/replicate/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func pullSecrets(names ...string) []v1.LocalObjectReference {
+	refs := make([]v1.LocalObjectReference, 0)
+	for _, name := range names {
+		refs = append(refs, v1.LocalObjectReference{Name: name})
+	}
+	return refs
+}
+
+func TestMergeKeepsExistingPullSecrets(t *testing.T) {
+	source := &v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "default", Namespace: "registry", ResourceVersion: "1"},
+		ImagePullSecrets: pullSecrets("registry-a", "shared"),
+	}
+	target := &v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "default", Namespace: "tenant", Annotations: map[string]string{}},
+		ImagePullSecrets: pullSecrets("own", "shared"),
+	}
+
+	copyServiceAccount(source, target, true)
+
+	assert.Equal(t, pullSecrets("own", "shared", "registry-a"), target.ImagePullSecrets)
+	assert.Equal(t, "registry-a", target.Annotations[common.ReplicatedKeysAnnotation])
+
+	source.ImagePullSecrets = pullSecrets("registry-b")
+	copyServiceAccount(source, target, true)
+
+	assert.Equal(t, pullSecrets("own", "shared", "registry-b"), target.ImagePullSecrets)
+	assert.Equal(t, "registry-b", target.Annotations[common.ReplicatedKeysAnnotation])
+
+	stripped := withoutReplicatedPullSecrets(target)
+	assert.Equal(t, pullSecrets("own", "shared"), stripped.ImagePullSecrets)
+	assert.NotContains(t, stripped.Annotations, common.ReplicatedKeysAnnotation)
+}
+
+func TestCopyReplacesPullSecretsWithoutMerge(t *testing.T) {
+	automount := false
+	source := &v1.ServiceAccount{
+		ObjectMeta:                   metav1.ObjectMeta{Name: "builder", Namespace: "registry", ResourceVersion: "1"},
+		ImagePullSecrets:             pullSecrets("registry-a"),
+		AutomountServiceAccountToken: &automount,
+	}
+	target := &v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "builder", Namespace: "tenant", Annotations: map[string]string{}},
+		ImagePullSecrets: pullSecrets("own"),
+	}
+
+	copyServiceAccount(source, target, false)
+
+	assert.Equal(t, pullSecrets("registry-a"), target.ImagePullSecrets)
+	assert.Equal(t, &automount, target.AutomountServiceAccountToken)
+	assert.Empty(t, withoutReplicatedPullSecrets(target).ImagePullSecrets)
+}
+
+func TestDeletingMergedReplicaKeepsServiceAccount(t *testing.T) {
+	existing := &v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "default", Namespace: "tenant", ResourceVersion: "1"},
+		ImagePullSecrets: pullSecrets("own"),
+	}
+	client := fake.NewSimpleClientset(existing)
+	repl := NewReplicator(client, time.Minute, true, false, 5).(*Replicator)
+	require.NoError(t, repl.Store.Add(existing))
+
+	source := &v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "default",
+			Namespace:       "registry",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{common.MergeImagePullSecretsAnnotation: "true"},
+		},
+		ImagePullSecrets: pullSecrets("registry-a"),
+	}
+	require.NoError(t, repl.ReplicateObjectTo(source, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}}))
+
+	merged, err := client.CoreV1().ServiceAccounts("tenant").Get("default", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.True(t, repl.IsReplicaOf(merged, source))
+	assert.NotContains(t, merged.Annotations, common.CreatedReplicaAnnotation)
+
+	require.NoError(t, repl.DeleteReplicatedResource(merged))
+
+	unmerged, err := client.CoreV1().ServiceAccounts("tenant").Get("default", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, pullSecrets("own"), unmerged.ImagePullSecrets)
+	assert.NotContains(t, unmerged.Labels, common.ReplicatedFromLabel)
+	assert.NotContains(t, unmerged.Labels, common.ReplicatedFromKindLabel)
+}
+
+func TestDeletingReplicaKeepsExistingServiceAccount(t *testing.T) {
+	existing := &v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "default", Namespace: "tenant", ResourceVersion: "1"},
+		ImagePullSecrets: pullSecrets("own"),
+	}
+	client := fake.NewSimpleClientset(existing)
+	repl := NewReplicator(client, time.Minute, true, false, 5).(*Replicator)
+	require.NoError(t, repl.Store.Add(existing))
+
+	source := &v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "default", Namespace: "registry", ResourceVersion: "1"},
+		ImagePullSecrets: pullSecrets("registry-a"),
+	}
+	require.NoError(t, repl.ReplicateObjectTo(source, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}}))
+
+	replaced, err := client.CoreV1().ServiceAccounts("tenant").Get("default", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, pullSecrets("registry-a"), replaced.ImagePullSecrets)
+	assert.NotContains(t, replaced.Annotations, common.ReplicatedKeysAnnotation)
+
+	require.NoError(t, repl.DeleteReplicatedResource(replaced))
+
+	released, err := client.CoreV1().ServiceAccounts("tenant").Get("default", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, released.ImagePullSecrets)
+	assert.NotContains(t, released.Labels, common.ReplicatedFromLabel)
+	assert.NotContains(t, released.Labels, common.ReplicatedFromKindLabel)
+}
+
+func TestDeletingCreatedReplicaDeletesServiceAccount(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	repl := NewReplicator(client, time.Minute, true, false, 5).(*Replicator)
+
+	source := &v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "deployer", Namespace: "registry", ResourceVersion: "1"},
+		ImagePullSecrets: pullSecrets("registry-a"),
+	}
+	require.NoError(t, repl.ReplicateObjectTo(source, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}}))
+
+	created, err := client.CoreV1().ServiceAccounts("tenant").Get("deployer", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repl.DeleteReplicatedResource(created))
+
+	_, err = client.CoreV1().ServiceAccounts("tenant").Get("deployer", metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+}